@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
@@ -19,6 +20,7 @@ import (
 	"sync"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"bytes"
 
@@ -37,6 +39,8 @@ var currentSettings *YcmdSettings
 var currentSettingsLock sync.Mutex
 var currentPort string
 var currentPortLock sync.Mutex
+var currentIdeSettings *IdeSettings
+var currentIdeSettingsLock sync.Mutex
 
 func Python() string {
 	pyPath, err := exec.LookPath("python")
@@ -77,12 +81,67 @@ func DefaultSettings() *YcmdSettings {
 	return ycmdSettings
 }
 
+// Where the user keeps their acmeide configuration, e.g. settings.json.
+func ConfigDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Println("ConfigDir: " + err.Error())
+		return ""
+	}
+	return filepath.Join(home, "lib", "acmeide")
+}
+
+func DefaultIdeSettings() *IdeSettings {
+	ideSettings, err := NewIdeSettingsFromFile("./default_ide_settings.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if configDir := ConfigDir(); configDir != "" {
+		err = ideSettings.MergeFileIfExists(filepath.Join(configDir, "settings.json"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	return ideSettings
+}
+
+func UpdateCurrentIdeSettings(settings *IdeSettings) {
+	currentIdeSettingsLock.Lock()
+	defer currentIdeSettingsLock.Unlock()
+	currentIdeSettings = settings
+}
+
+// The returned settings are shared and must not be modified. Use UpdateCurrentIdeSettings instead.
+func GetIdeSettings() *IdeSettings {
+	currentIdeSettingsLock.Lock()
+	defer currentIdeSettingsLock.Unlock()
+	return currentIdeSettings
+}
+
 func UpdateCurrentSettings(settings *YcmdSettings) {
 	currentSettingsLock.Lock()
 	defer currentSettingsLock.Unlock()
 	currentSettings = settings
 }
 
+func SeedsIdentifiersWithSyntax() bool {
+	currentSettingsLock.Lock()
+	defer currentSettingsLock.Unlock()
+	return currentSettings != nil && currentSettings.SeedIdentifiersWithSyntax != 0
+}
+
+func UsesUltiSnipsCompleter() bool {
+	currentSettingsLock.Lock()
+	defer currentSettingsLock.Unlock()
+	return currentSettings != nil && currentSettings.UseUltiSnipsCompleter != 0
+}
+
+func CollectsIdentifiersFromTagsFiles() bool {
+	currentSettingsLock.Lock()
+	defer currentSettingsLock.Unlock()
+	return currentSettings != nil && currentSettings.CollectIdentifiersFromTagsFiles != 0
+}
+
 func SetCurrentPort(port string) {
 	currentPortLock.Lock()
 	defer currentPortLock.Unlock()
@@ -124,10 +183,12 @@ func StartAndWaitForYcmd(pathToYcmd string) {
 		log.Fatal(err)
 	}
 	SetCurrentPort(strconv.Itoa(port))
+	ResetYcmdClient()
 	optionsFile := WriteNamedTemporaryFileOf(GetSettingsJson())
 	cmd := exec.Command(
 		Python(),
 		pathToYcmd,
+		fmt.Sprintf("--host=%s", YcmdHost),
 		fmt.Sprintf("--port=%s", GetCurrentPort()),
 		fmt.Sprintf("--options_file=%s", optionsFile),
 		fmt.Sprintf("--idle_suicide_seconds=%s", "300"),
@@ -178,7 +239,7 @@ func ExtraHeaders(req *http.Request, method, path, body string) {
 }
 
 func CreateRequestForGetHandler(handler string) (*http.Request, error) {
-	rawurl := fmt.Sprintf("http://localhost:%s/%s", GetCurrentPort(), handler)
+	rawurl := YcmdUrl(handler)
 	uri, err := url.Parse(rawurl)
 	if err != nil {
 		return nil, err
@@ -192,7 +253,7 @@ func CreateRequestForGetHandler(handler string) (*http.Request, error) {
 }
 
 func CreateRequestForPostHandler(handler string, body []byte) (*http.Request, error) {
-	rawurl := fmt.Sprintf("http://localhost:%s/%s", GetCurrentPort(), handler)
+	rawurl := YcmdUrl(handler)
 	uri, err := url.Parse(rawurl)
 	if err != nil {
 		return nil, err
@@ -207,21 +268,28 @@ func CreateRequestForPostHandler(handler string, body []byte) (*http.Request, er
 	return req, nil
 }
 
-func GetHandler(handler string) (interface{}, error) {
+func GetHandler(ctx context.Context, handler string) (interface{}, error) {
 	req, err := CreateRequestForGetHandler(handler)
 	if err != nil {
 		return nil, err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	timeout := CurrentRequestTimeout(handler, "")
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := GetYcmdClient().Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return nil, requestError(ctx, handler, timeout, err)
 	}
 	log.Println(resp.Status)
 	defer resp.Body.Close()
 	blob, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, requestError(ctx, handler, timeout, err)
+	}
+	RecordLatency(handler, time.Since(start))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, NewYcmdError(handler, resp.StatusCode, blob)
 	}
 	var i interface{}
 	err = json.Unmarshal(blob, &i)
@@ -231,7 +299,7 @@ func GetHandler(handler string) (interface{}, error) {
 	return i, nil
 }
 
-func PostHandler(handler string, request *YcmdRequest) ([]byte, error) {
+func PostHandler(ctx context.Context, handler string, request *YcmdRequest) ([]byte, error) {
 	body, err := json.Marshal(request)
 	if err != nil {
 		return nil, err
@@ -241,20 +309,28 @@ func PostHandler(handler string, request *YcmdRequest) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	subcommand := ""
+	if len(request.CommandArguments) > 0 {
+		subcommand = request.CommandArguments[0]
+	}
+	timeout := CurrentRequestTimeout(handler, subcommand)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := GetYcmdClient().Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return nil, requestError(ctx, handler, timeout, err)
 	}
 	log.Println(resp.Status)
 	defer resp.Body.Close()
 	blob, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, requestError(ctx, handler, timeout, err)
 	}
+	RecordLatency(RequestTimeoutKey(handler, subcommand), time.Since(start))
 	log.Printf("Raw Ycmd Response: %s\n", string(blob))
-	if resp.StatusCode == 500 {
-		return nil, errors.New(string(blob))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, NewYcmdError(handler, resp.StatusCode, blob)
 	}
 	return blob, nil
 }
@@ -264,7 +340,7 @@ func IsReady(duration time.Duration) bool {
 	var err error
 	tick := time.Tick(duration)
 	for range tick {
-		data, err = GetHandler("ready")
+		data, err = GetHandler(context.Background(), "ready")
 		if err != nil {
 			log.Println(err)
 		} else {
@@ -275,7 +351,7 @@ func IsReady(duration time.Duration) bool {
 }
 
 const GlobalWindowSuffix = "+IDE"
-const PythonTag = "Goto Nav Diag"
+const PythonTag = "Goto Nav Diag Next Prev Problems Outline Sig Hints Calls Hier Fmt Imports Fix Snip Tags Stats Stop"
 
 type WindowType int
 
@@ -305,10 +381,36 @@ var windowSuffixes = map[string]WindowType{
 	".hh":              CcWindow,
 	".H":               CcWindow,
 	".hpp":             CcWindow,
+	".java":            JavaWindow,
+	".go":              GoWindow,
+	".js":              JavascriptWindow,
+	".rs":              RustWindow,
 	"/":                DirectoryWindow,
 	GlobalWindowSuffix: GlobalWindowWindow,
 }
 
+// The ycmd filetypes for each file suffix we can run semantic commands on.
+var windowFiletypes = map[string][]string{
+	".py":   {"python"},
+	".c":    {"c"},
+	".cpp":  {"cpp"},
+	".cc":   {"cpp"},
+	".C":    {"cpp"},
+	".h":    {"cpp"},
+	".hh":   {"cpp"},
+	".H":    {"cpp"},
+	".hpp":  {"cpp"},
+	".java": {"java"},
+	".go":   {"go"},
+	".js":   {"javascript"},
+	".rs":   {"rust"},
+}
+
+// The ycmd filetypes of a window, based on its name. Nil if ycmd can't do anything semantic with it.
+func FiletypesFor(winName string) []string {
+	return windowFiletypes[filepath.Ext(winName)]
+}
+
 func WindowSuffixes() (map[string]WindowType, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -349,6 +451,17 @@ const (
 	AcmeButtonThree AcmeButton = iota
 )
 
+func (b AcmeButton) String() string {
+	switch b {
+	case AcmeButtonTwo:
+		return "2"
+	case AcmeButtonThree:
+		return "3"
+	default:
+		return "?"
+	}
+}
+
 type AcmeArea int
 
 const (
@@ -395,29 +508,40 @@ type LineAndColumn struct {
 }
 
 func GetAcmeWindowLineAndColumn(a *acme.Win, body string) (*LineAndColumn, error) {
+	start, _, err := GetAcmeWindowSelection(a, body)
+	return start, err
+}
+
+// The start and end of dot, as ycmd counts them: 1-based lines and 1-based byte columns.
+func GetAcmeWindowSelection(a *acme.Win, body string) (*LineAndColumn, *LineAndColumn, error) {
 	err := a.Ctl("addr=dot")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	q0, q1, err := a.ReadAddr()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if len(body) <= q0 {
-		return nil, errors.New(fmt.Sprintf("Acme body size is smaller than q0: %d <= %d", len(body), q0))
+	runes := []rune(body)
+	if len(runes) < q1 || q1 < q0 {
+		return nil, nil, errors.New(fmt.Sprintf("Acme body size is smaller than dot: %d < #%d,#%d", len(runes), q0, q1))
 	}
 	log.Printf("Acme Window Addr: %d, %d", q0, q1)
-	lastLinePosition := 0
-	lineAndColumn := &LineAndColumn{Line: 1}
+	return LineAndColumnOfRuneOffset(runes, q0), LineAndColumnOfRuneOffset(runes, q1), nil
+}
 
-	for i := 0; i < q0; i++ {
+// Acme addresses runes, ycmd wants byte columns.
+func LineAndColumnOfRuneOffset(body []rune, q int) *LineAndColumn {
+	lineAndColumn := &LineAndColumn{Line: 1, Column: 1}
+	for i := 0; i < q && i < len(body); i++ {
 		if body[i] == '\n' {
-			lastLinePosition = i
 			lineAndColumn.Line++
+			lineAndColumn.Column = 1
+		} else {
+			lineAndColumn.Column += utf8.RuneLen(body[i])
 		}
 	}
-	lineAndColumn.Column = q0 - lastLinePosition
-	return lineAndColumn, nil
+	return lineAndColumn
 }
 
 type Ide interface {
@@ -467,6 +591,59 @@ func (p *DefaultIde) Setup() error {
 	return nil
 }
 
+// Commands that don't need a source window, so they work from directories, +IDE and results windows too.
+var DefaultIdeCommands = map[string]struct{}{
+	"Sym":      {},
+	"Stop":     {},
+	"Stats":    {},
+	"Problems": {},
+}
+
+func (p *DefaultIde) IsIdeCommand(e *acme.Event) bool {
+	return IsIdeCommandEvent(e, func(command string) bool {
+		_, ok := DefaultIdeCommands[command]
+		return ok
+	})
+}
+
+func (p *DefaultIde) HandleCommand(ctx context.Context, i *IdeCommand) error {
+	// Windows we create ourselves have no name yet when acme tells us about them.
+	if name, err := AcmeWinName(p.acmeWin); err == nil && name != "" {
+		p.Rename(name)
+	}
+	if i.Command == "Sym" {
+		return SearchProjectSymbols(ctx, p.Name(), i.Args)
+	}
+	if i.Command == "Stats" {
+		return ShowLatencies(p.Name())
+	}
+	if i.Command == "Problems" {
+		return ShowProblems(p.Name(), i.Args)
+	}
+	return nil
+}
+
+// Handlers for clicks in the windows we fill in: extra conf prompts, FixIt lists, trees, hints and results. Each only
+// looks at the event and the window, and returns the job that does the work, or nil if the event wasn't for it and
+// should be passed back to acme.
+var BodyClickHandlers = []func(ide Ide, win *acme.Win, e *acme.Event) (*IdeJob, error){
+	HandleExtraConfClick,
+	HandleFixItClick,
+	HandleTreeClick,
+	HandleHintsClick,
+	HandleResultsClick,
+}
+
+func HandleBodyClick(ide Ide, win *acme.Win, e *acme.Event) (*IdeJob, error) {
+	for _, handler := range BodyClickHandlers {
+		job, err := handler(ide, win, e)
+		if job != nil || err != nil {
+			return job, err
+		}
+	}
+	return nil, nil
+}
+
 func (p *DefaultIde) Watch() {
 	events := p.acmeWin.EventChan()
 	for {
@@ -474,7 +651,21 @@ func (p *DefaultIde) Watch() {
 		if !ok {
 			break
 		}
-		err := CheckEventForHistoryAddition(e)
+		if p.IsIdeCommand(e) {
+			QueueIdeCommand(p, p.acmeWin, NewIdeCommand(e), p.HandleCommand)
+			continue
+		}
+		// The work a click sets off waits its turn on the window's worker, so Watch never waits for ycmd.
+		job, err := HandleBodyClick(p, p.acmeWin, e)
+		if err != nil {
+			ReportError(p, err)
+			continue
+		}
+		if job != nil {
+			QueueIdeJob(p, p.acmeWin, job)
+			continue
+		}
+		err = CheckEventForHistoryAddition(e)
 		if err != nil {
 			log.Printf("Error recording history entry for %s: %+v\n", p.Name(), e)
 		}
@@ -484,15 +675,20 @@ func (p *DefaultIde) Watch() {
 }
 
 func (p *DefaultIde) Teardown() {
+	ForgetWindowTag(p.Id())
 	p.acmeWin.CloseFiles()
 }
 
 
+// PythonIde runs the ycmd semantic commands for a window. Despite the name it serves every source window in
+// sourceWindowTypes; the filetypes sent to ycmd follow the window name.
 type PythonIde struct {
 	id      int
 	name    string
 	isSetup bool
 	acmeWin *acme.Win
+	// Whether +Doc currently shows a signature from this window, so a closing paren knows to clear it.
+	showingSignature bool
 }
 
 func (p *PythonIde) hasIdeTag() (bool, error) {
@@ -517,13 +713,55 @@ func (p *PythonIde) Name() string {
 }
 
 func (p *PythonIde) Rename(name string) {
+	oldName := p.name
 	p.name = name
+	if oldName == name {
+		return
+	}
+	RenameBuffer(p.Id(), name)
+	ForgetSnippet(p.Id())
+	ForgetClosedFileDiagnostics(oldName)
+	for _, filetype := range p.Filetypes() {
+		StartMessagePoller(filetype)
+	}
 }
 
 func (p *PythonIde) Id() int {
 	return p.id
 }
 
+func (p *PythonIde) Filetypes() []string {
+	return FiletypesFor(p.Name())
+}
+
+// Build a request for the current window contents and dot.
+func (p *PythonIde) NewYcmdRequest(commandArguments ...string) (*YcmdRequest, error) {
+	body, lineAndColumn, end, err := AcmeBufferSnapshot(p.Id(), p.acmeWin)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("lineAndColumn: %d, %d\n", lineAndColumn.Line, lineAndColumn.Column)
+	ycmdRequest := &YcmdRequest{
+		LineNum:      lineAndColumn.Line,
+		ColumnNum:    lineAndColumn.Column,
+		Filepath:     p.Name(),
+		FileContents: body,
+		Filetypes:    p.Filetypes(),
+	}
+	if *end != *lineAndColumn {
+		// Something is selected, for range formatting, code actions and the like.
+		ycmdRequest.Range = &YcmdRange{
+			Start: YcmdPosition{LineNum: lineAndColumn.Line, ColumnNum: lineAndColumn.Column},
+			End:   YcmdPosition{LineNum: end.Line, ColumnNum: end.Column},
+		}
+	}
+	if len(commandArguments) > 0 {
+		ycmdRequest.CommandArguments = commandArguments
+	}
+	ycmdRequest.OtherFileData = DirtyBufferFileData(p.Name())
+	return ycmdRequest, nil
+}
+
 func (p *PythonIde) Setup() error {
 	var err error
 	p.acmeWin, err = acme.Open(p.Id(), nil)
@@ -537,33 +775,74 @@ func (p *PythonIde) Setup() error {
 	if !hasIdeTag {
 		p.setupIdeTag()
 	}
+	RegisterBuffer(p.Id(), p.Name())
+	for _, filetype := range p.Filetypes() {
+		StartMessagePoller(filetype)
+	}
+	QueueIdeJob(p, p.acmeWin, &IdeJob{Name: "Visit", Run: p.VisitBuffer})
 	return nil
 }
 
 func (p *PythonIde) Teardown() {
+	UnregisterBuffer(p.Id())
+	ForgetSnippet(p.Id())
+	ForgetClosedFileDiagnostics(p.Name())
+	ForgetWindowTag(p.Id())
+	ForgetShadowResyncs(p.Id())
 	p.acmeWin.CloseFiles()
 }
 
 var PythonIdeCommands = map[string]struct{}{
-	"Goto": {},
-	"Nav":  {},
-	"Diag": {},
+	"Goto":     {},
+	"Nav":      {},
+	"Diag":     {},
+	"Outline":  {},
+	"Sym":      {},
+	"Sig":      {},
+	"Hints":    {},
+	"Calls":    {},
+	"Hier":     {},
+	"Fmt":      {},
+	"Imports":  {},
+	"Fix":      {},
+	"Stop":     {},
+	"Stats":    {},
+	"Next":     {},
+	"Prev":     {},
+	"Problems": {},
+	"Tags":     {},
+	"Snip":     {},
 }
 
 type IdeCommand struct {
 	Command string
-	Area    AcmeArea
-	Button  AcmeButton
+	// Words swept along with the command in the tag, e.g. "impl" in "Goto impl", followed by any chorded argument.
+	Args   []string
+	Area   AcmeArea
+	Button AcmeButton
 }
 
 func NewIdeCommand(e *acme.Event) *IdeCommand {
 	area, _ := WhichAcmeArea(e)
 	button, _ := WhichAcmeButton(e)
-	ideCommand := &IdeCommand{Command: string(e.Text), Area: area, Button: button}
+	fields := strings.Fields(string(e.Text))
+	ideCommand := &IdeCommand{Area: area, Button: button}
+	if len(fields) > 0 {
+		ideCommand.Command = fields[0]
+		ideCommand.Args = fields[1:]
+	}
+	ideCommand.Args = append(ideCommand.Args, strings.Fields(string(e.Arg))...)
 	return ideCommand
 }
 
 func (p *PythonIde) IsIdeCommand(e *acme.Event) bool {
+	return IsIdeCommandEvent(e, func(command string) bool {
+		_, ok := PythonIdeCommands[command]
+		return ok || IsGoToSubcommand(command)
+	})
+}
+
+func IsIdeCommandEvent(e *acme.Event, isCommand func(command string) bool) bool {
 	// The area has to be the tag. We don't process anything in the body.
 	area, err := WhichAcmeArea(e)
 	if err != nil {
@@ -585,26 +864,23 @@ func (p *PythonIde) IsIdeCommand(e *acme.Event) bool {
 	}
 
 	// We don't override user inserted strings. Commands have to match the IDE commands.
-	_, ok := PythonIdeCommands[string(e.Text)]
-	return ok
+	fields := strings.Fields(string(e.Text))
+	if len(fields) == 0 {
+		return false
+	}
+	return isCommand(fields[0])
 }
 
 func AcmeWinIsDirectory(win *acme.Win) (bool, error) {
-	var ctlBytes []byte
-	_, err := win.Read("ctl", ctlBytes)
+	ctlBytes, err := win.ReadAll("ctl")
 	if err != nil {
 		return false, err
 	}
-	ctl := string(ctlBytes)
-	fields := strings.Split(ctl, " ")
-	if len(fields) < 5 {
-		return false, errors.New(fmt.Sprintf("Invalid ctl: %s", ctl))
-	}
-	directory, err := strconv.ParseInt(strings.TrimSpace(fields[4]), 10, 32)
+	ctl, err := ParseAcmeCtl(string(ctlBytes))
 	if err != nil {
 		return false, err
 	}
-	return directory == 1, nil
+	return ctl.IsDirectory, nil
 }
 
 func AcmeWinIsDirty(win *acme.Win) (bool, error) {
@@ -613,18 +889,11 @@ func AcmeWinIsDirty(win *acme.Win) (bool, error) {
 		log.Println("AcmeWinIsDirty: Error reading ctl: " + err.Error())
 		return false, err
 	}
-	ctl := string(ctlBytes)
-	fields := strings.Fields(ctl)
-	if len(fields) < 5 {
-		return false, errors.New(fmt.Sprintf("Invalid ctl: %s", ctl))
-	}
-
-	log.Printf("Ctl: %s\n", strings.Join(fields, ";"))
-	dirty, err := strconv.ParseInt(strings.TrimSpace(fields[5]), 10, 32)
+	ctl, err := ParseAcmeCtl(string(ctlBytes))
 	if err != nil {
 		return false, err
 	}
-	return dirty == 1, nil
+	return ctl.IsDirty, nil
 }
 
 func AcmeFilepathIsAlreadyOpen(filepath string) (bool, error) {
@@ -640,7 +909,28 @@ func AcmeFilepathIsAlreadyOpen(filepath string) (bool, error) {
 	return false, nil
 }
 
-func AcmeJumpTo(ide Ide, win *acme.Win, location Location, pushHistory bool) error {
+func AcmeJumpTo(ide Ide, win *acme.Win, location Location, policy NavigationPolicy, pushHistory bool) error {
+	var err error
+	switch policy {
+	case NavigateNewWindow:
+		err = AcmeOpenInNewWindow(location)
+	case NavigateReuseWindow:
+		err = AcmePlumb(location)
+	case NavigatePreviewWindow:
+		err = AcmeOpenInPreviewWindow(ide.Id(), location)
+	default:
+		err = acmeJumpToAuto(ide, win, location)
+	}
+	if err != nil {
+		return err
+	}
+	if pushHistory {
+		PushHistory(location)
+	}
+	return nil
+}
+
+func acmeJumpToAuto(ide Ide, win *acme.Win, location Location) error {
 	acmeWinIsDirty, err := AcmeWinIsDirty(win)
 	if err != nil {
 		return err
@@ -660,66 +950,25 @@ func AcmeJumpTo(ide Ide, win *acme.Win, location Location, pushHistory bool) err
 		log.Println("AcmeJumpTo: Window is dirty or same window.")
 		// If the window is dirty, plumb the location to a new window so we don't lose any changes. If the window is
 		// already open somewhere, zap to it. We can also do this when we're zapping somewhere else in the same file.
-		cmd := exec.Command("plumb", location.String())
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			log.Println("plumb " + location.String()+ ": " + string(output))
-			return err
-		}
-		if pushHistory {
-			PushHistory(location)
-		}
-	} else {
-		// Special case to open in place if the window is clean, and the destination file isn't already open.
-		log.Println("AcmeJumpTo: Window is clean. Replacing")
-		// Open the new location in place
-		err = win.Addr("0,$")
-		if err != nil {
-			return err
-		}
-		log.Println("AcmeJumpTo: Set addr")
-		fileContents, err := ioutil.ReadFile(location.Path())
-		if err != nil {
-			return err
-		}
-		log.Println("AcmeJumpTo: Read file " + location.Path())
-		_, err = win.Write("data", fileContents)
-		if err != nil {
-			return err
-		}
-		log.Println("AcmeJumpTo: wrote data")
-		err = win.Name(location.Path())
-		if err != nil {
-			return err
-		}
-		ide.Rename(location.Path())
-		log.Println("AcmeJumpTo: wrote name")
-		err = win.Addr(location.Addr())
-		if err != nil {
-			log.Printf("AcmeJumpTo: error writing addr: %s\n", location.Addr())
-			return err
-		}
-		err = win.Ctl("dot=addr")
-		if err != nil {
-			log.Printf("AcmeJumpTo: error writing ctl: dot=addr\n")
-		}
-		err = win.Ctl("clean")
-		if err != nil {
-			log.Printf("AcmeJumpTo: error writing ctl: clean\n")
-		}
-		err = win.Ctl("show")
-		if err != nil {
-			log.Printf("AcmeJumpTo: error writing ctl: show\n")
-		}
-		if pushHistory {
-			PushHistory(location)
-		}
+		return AcmePlumb(location)
 	}
+	// Special case to open in place if the window is clean, and the destination file isn't already open.
+	log.Println("AcmeJumpTo: Window is clean. Replacing")
+	err = AcmeLoadLocation(win, location)
+	if err != nil {
+		return err
+	}
+	ide.Rename(location.Path())
 	return nil
 }
 
 func (p *PythonIde) WriteToErrors(content string) error {
-	cmd := exec.Command("9p", "write", fmt.Sprintf("acme/%d/errors", p.Id()))
+	return AcmeWriteToErrors(p.Id(), p.Name(), content)
+}
+
+// Write to the +Errors window of the directory the window is in, and show what was written.
+func AcmeWriteToErrors(winId int, winName string, content string) error {
+	cmd := exec.Command("9p", "write", fmt.Sprintf("acme/%d/errors", winId))
 	inPipe, err := cmd.StdinPipe()
 	if err != nil {
 		return err
@@ -739,16 +988,16 @@ func (p *PythonIde) WriteToErrors(content string) error {
 	if err != nil {
 		return err
 	}
-	seeking := fmt.Sprintf("%s/+Errors", filepath.Dir(p.Name()))
+	seeking := fmt.Sprintf("%s/+Errors", filepath.Dir(winName))
 
 	for _, window := range windows {
 		if window.Name == seeking {
 			return func(lines int) error {
 				w, err := acme.Open(window.ID, nil)
-				defer w.CloseFiles()
 				if err != nil {
 					return err
 				}
+				defer w.CloseFiles()
 				w.Addr("$-%d,$", lines-1) // Safe coz we pad newlines. Otherwise would be dangerous.
 				w.Ctl("dot=addr")
 				w.Ctl("show")
@@ -759,114 +1008,127 @@ func (p *PythonIde) WriteToErrors(content string) error {
 	return nil
 }
 
-func (p *PythonIde) HandleCommand(i *IdeCommand) error {
+func (p *PythonIde) HandleCommand(ctx context.Context, i *IdeCommand) error {
 	if i.Command == "Nav" && i.Button == AcmeButtonThree {
-		err := BackHistory(p, p.acmeWin)
+		err := BackHistory(p, p.acmeWin, CurrentNavigationPolicy(i.Command, i.Button))
 		if err != nil {
 			return err
 		}
 		goto DONE
 	}
 	if i.Command == "Nav" && i.Button == AcmeButtonTwo {
-		err := ForwardHistory(p, p.acmeWin)
+		err := ForwardHistory(p, p.acmeWin, CurrentNavigationPolicy(i.Command, i.Button))
 		if err != nil {
 			return err
 		}
 		goto DONE
 	}
-	if i.Command == "Goto" && i.Button == AcmeButtonThree {
-		// Right click on Goto is like GoToDefinition
-		body, err := GetAcmeWindowBody(p.acmeWin)
+	if i.Command == "Goto" || IsGoToSubcommand(i.Command) {
+		subcommand, args, err := GoToSubcommandFor(i)
 		if err != nil {
 			return err
 		}
-		lineAndColumn, err := GetAcmeWindowLineAndColumn(p.acmeWin, body)
+		err = p.RunGoToCommand(ctx, i, subcommand, args)
 		if err != nil {
 			return err
 		}
-		log.Printf("lineAndColumn: %d, %d\n", lineAndColumn.Line, lineAndColumn.Column)
-		ycmdRequest := &YcmdRequest{
-			LineNum:          lineAndColumn.Line,
-			ColumnNum:        lineAndColumn.Column,
-			Filepath:         p.Name(),
-			FileContents:     body,
-			CommandArguments: []string{"GoTo"},
-			Filetypes:        []string{"python"},
+		goto DONE
+	}
+	if i.Command == "Diag" {
+		err := p.ShowDiagnostics(ctx)
+		if err != nil {
+			return err
 		}
-		blob, err := PostHandler("run_completer_command", ycmdRequest)
+		goto DONE
+	}
+	if i.Command == "Next" || i.Command == "Prev" {
+		err := p.JumpToDiagnostic(i.Command == "Next")
 		if err != nil {
 			return err
 		}
-		var (
-			fileLocation  = FileLocation{}
-			fileLocations = FileLocations{}
-		)
-		err = json.Unmarshal(blob, &fileLocation)
-		if err == nil {
-			err = AcmeJumpTo(p, p.acmeWin, &fileLocation, true)
-			if err != nil {
-				return err
-			}
-			goto DONE
+		goto DONE
+	}
+	if i.Command == "Outline" {
+		err := p.ShowOutline(ctx)
+		if err != nil {
+			return err
 		}
-		err = json.Unmarshal(blob, &fileLocations)
-		if err == nil {
-			log.Printf("Received Multi Response: %+v\n", fileLocations)
-			options := fmt.Sprintf("\n%s\n", fileLocations.String())
-			err = p.WriteToErrors(options)
-			if err != nil {
-				log.Printf("Error writing Errors: %s\n", err)
-				return err
-			}
-			goto DONE
+		goto DONE
+	}
+	if i.Command == "Sym" {
+		err := p.SearchSymbols(ctx, i.Args)
+		if err != nil {
+			return err
 		}
-		return err
+		goto DONE
 	}
-	if i.Command == "Goto" && i.Button == AcmeButtonTwo {
-		// Left click on Goto is like GoToReferences
-		body, err := GetAcmeWindowBody(p.acmeWin)
+	if i.Command == "Sig" {
+		err := p.ShowSignatureHelp(ctx)
 		if err != nil {
 			return err
 		}
-		lineAndColumn, err := GetAcmeWindowLineAndColumn(p.acmeWin, body)
+		goto DONE
+	}
+	if i.Command == "Hints" {
+		err := p.ShowInlayHints(ctx)
 		if err != nil {
 			return err
 		}
-		log.Printf("lineAndColumn: %d, %d\n", lineAndColumn.Line, lineAndColumn.Column)
-		ycmdRequest := &YcmdRequest{
-			LineNum:          lineAndColumn.Line,
-			ColumnNum:        lineAndColumn.Column,
-			Filepath:         p.Name(),
-			FileContents:     body,
-			CommandArguments: []string{"GoToReferences"},
-			Filetypes:        []string{"python"},
+		goto DONE
+	}
+	if i.Command == "Calls" {
+		err := p.ShowCallHierarchy(ctx, i.Args)
+		if err != nil {
+			return err
 		}
-		blob, err := PostHandler("run_completer_command", ycmdRequest)
+		goto DONE
+	}
+	if i.Command == "Hier" {
+		err := p.ShowTypeHierarchy(ctx)
 		if err != nil {
 			return err
 		}
-		var (
-			fileLocation  = FileLocation{}
-			fileLocations = FileLocations{}
-		)
-		err = json.Unmarshal(blob, &fileLocation)
-		if err == nil {
-			err = AcmeJumpTo(p, p.acmeWin, &fileLocation, true)
-			if err != nil {
-				return err
-			}
-			PushHistory(&fileLocation)
-			goto DONE
+		goto DONE
+	}
+	if i.Command == "Fix" {
+		err := p.ShowFixIts(ctx)
+		if err != nil {
+			return err
 		}
-		err = json.Unmarshal(blob, &fileLocations)
-		if err == nil {
-			log.Printf("Received Multi Response: %+v\n", fileLocations)
-			options := fmt.Sprintf("\n%s\n", fileLocations.String())
-			err = p.WriteToErrors(options)
-			if err != nil {
-				log.Printf("Error writing Errors: %s\n", err)
-				return err
-			}
+		goto DONE
+	}
+	if i.Command == "Stats" {
+		err := ShowLatencies(p.Name())
+		if err != nil {
+			return err
+		}
+		goto DONE
+	}
+	if i.Command == "Problems" {
+		err := ShowProblems(p.Name(), i.Args)
+		if err != nil {
+			return err
+		}
+		goto DONE
+	}
+	if i.Command == "Tags" {
+		err := p.ShowTagFiles(ctx)
+		if err != nil {
+			return err
+		}
+		goto DONE
+	}
+	if i.Command == "Snip" {
+		err := p.Snip(ctx)
+		if err != nil {
+			return err
+		}
+		goto DONE
+	}
+	if subcommand, ok := FixItCommands[i.Command]; ok {
+		err := p.RunFixItCommand(ctx, subcommand)
+		if err != nil {
+			return err
 		}
 		goto DONE
 	}
@@ -886,6 +1148,19 @@ func GetWinDot(win *acme.Win, winName string) (*DotLocation, error) {
 	return &DotLocation{Q0:q0, Q1:q1, Filepath:winName}, nil
 }
 
+// The text of the current selection in the window body.
+func AcmeDotText(win *acme.Win, body string) (string, error) {
+	dotLocation, err := GetWinDot(win, "")
+	if err != nil {
+		return "", err
+	}
+	runes := []rune(body)
+	if dotLocation.Q0 > dotLocation.Q1 || dotLocation.Q1 > len(runes) {
+		return "", errors.New(fmt.Sprintf("dot is outside the body: #%d,#%d", dotLocation.Q0, dotLocation.Q1))
+	}
+	return string(runes[dotLocation.Q0:dotLocation.Q1]), nil
+}
+
 func CheckEventForHistoryAddition(e *acme.Event) error {
 	area, _ := WhichAcmeArea(e)
 	button, _ := WhichAcmeButton(e)
@@ -902,28 +1177,89 @@ func CheckEventForHistoryAddition(e *acme.Event) error {
 
 func (p *PythonIde) Watch() {
 	events := p.acmeWin.EventChan()
+	resyncs := ShadowResyncRequests(p.Id())
 	for {
-		e, ok := <-events
+		var e *acme.Event
+		var ok bool
+		select {
+		case e, ok = <-events:
+		default:
+			// Only resync the shadow when no event is waiting to be applied to it.
+			select {
+			case e, ok = <-events:
+			case done := <-resyncs:
+				done <- ResyncShadow(p.Id(), p.acmeWin)
+				continue
+			}
+		}
 		if !ok {
 			break
 		}
 		if p.IsIdeCommand(e) {
-			ideCommand := NewIdeCommand(e)
-			err := p.HandleCommand(ideCommand)
-			if err != nil {
-				log.Printf("HandleCommand error: %s\n", err)
-			}
+			QueueIdeCommand(p, p.acmeWin, NewIdeCommand(e), p.HandleCommand)
 		} else {
+			UpdateBufferFromEvent(p.Id(), e)
+			AdjustSnippetFromEvent(p.Id(), e)
 			err := CheckEventForHistoryAddition(e)
 			if err != nil {
 				log.Printf("Error recording history entry for %s: %+v\n", p.Name(), e)
 			}
+			if IsPutEvent(e) {
+				p.QueuePut(e)
+				continue
+			}
 			p.acmeWin.WriteEvent(e)
+			if trigger, ok := SignatureHelpTrigger(e); ok {
+				QueueIdeJob(p, p.acmeWin, &IdeJob{Name: "Sig", Run: func(ctx context.Context) error {
+					return p.UpdateSignatureHelp(ctx, trigger)
+				}})
+			}
 			continue
 		}
 	}
 }
 
+// Put goes back to acme straight away unless it has to be formatted first, in which case it waits its turn behind the
+// window's other jobs. The outline and, if diagnostics_on_put says so, the diagnostics are refreshed after.
+func (p *PythonIde) QueuePut(e *acme.Event) {
+	if !p.HasFormatOnPut() {
+		p.acmeWin.WriteEvent(e)
+		if p.HasDiagnosticsOnPut() {
+			QueueIdeJob(p, p.acmeWin, &IdeJob{Name: "Diag", Run: func(ctx context.Context) error {
+				p.RefreshOutline(ctx)
+				return p.CheckDiagnosticsAfterPut(ctx)
+			}})
+		} else if isOpen, err := AcmeFilepathIsAlreadyOpen(OutlineWindowName(p.Name())); err == nil && isOpen {
+			QueueIdeJob(p, p.acmeWin, &IdeJob{Name: "Outline", Run: func(ctx context.Context) error {
+				p.RefreshOutline(ctx)
+				return nil
+			}})
+		}
+		return
+	}
+	QueueIdeJob(p, p.acmeWin, &IdeJob{Name: "Put", Keep: true, Run: func(ctx context.Context) error {
+		p.FormatBeforePut(ctx)
+		err := p.acmeWin.WriteEvent(e)
+		if err != nil {
+			return err
+		}
+		p.RefreshOutline(ctx)
+		if p.HasDiagnosticsOnPut() {
+			return p.CheckDiagnosticsAfterPut(ctx)
+		}
+		return nil
+	}})
+}
+
+// True if the event is the acme builtin Put, which acme runs once we write the event back.
+func IsPutEvent(e *acme.Event) bool {
+	if e.C2 != 'x' && e.C2 != 'X' || e.Flag&1 == 0 {
+		return false
+	}
+	fields := strings.Fields(string(e.Text))
+	return len(fields) > 0 && fields[0] == "Put"
+}
+
 type JumpRecord struct {
 	Prev     *JumpRecord
 	Next     *JumpRecord
@@ -945,11 +1281,11 @@ func PushHistory(location Location) {
 	history = jr
 }
 
-func ForwardHistory(ide Ide, win *acme.Win) error {
+func ForwardHistory(ide Ide, win *acme.Win, policy NavigationPolicy) error {
 	historyLock.Lock()
 	defer historyLock.Unlock()
 	if history.Next != nil {
-		err := AcmeJumpTo(ide, win, history.Next.Location, false)
+		err := AcmeJumpTo(ide, win, history.Next.Location, policy, false)
 		if err != nil {
 			return err
 		}
@@ -964,7 +1300,7 @@ func ForwardHistory(ide Ide, win *acme.Win) error {
 // Otherwise, if it looks like we're at the most recent history location,
 // jump back to the previous history location and update the history pointer.
 // If we can't jump back any further, do nothing.
-func BackHistory(ide Ide, win *acme.Win) error {
+func BackHistory(ide Ide, win *acme.Win, policy NavigationPolicy) error {
 	dotLocation, err := GetWinDot(win, ide.Name())
 	if err != nil {
 		return err
@@ -972,13 +1308,13 @@ func BackHistory(ide Ide, win *acme.Win) error {
 	historyLock.Lock()
 	defer historyLock.Unlock()
 	if dotLocation.Path() != history.Location.Path() {
-		err := AcmeJumpTo(ide, win, history.Location, false)
+		err := AcmeJumpTo(ide, win, history.Location, policy, false)
 		if err != nil {
 			return err
 		}
 	}
 	if history.Prev != nil {
-		err := AcmeJumpTo(ide, win, history.Prev.Location, false)
+		err := AcmeJumpTo(ide, win, history.Prev.Location, policy, false)
 		if err != nil {
 			return err
 		}
@@ -995,14 +1331,22 @@ func NewDefaultIde(winId int, winName string) *DefaultIde {
 	return &DefaultIde{id: winId, name: winName}
 }
 
+// The windows of source files ycmd has a completer for, which get the semantic commands.
+var sourceWindowTypes = map[WindowType]struct{}{
+	PythonWindow:     {},
+	CcWindow:         {},
+	JavaWindow:       {},
+	GoWindow:         {},
+	JavascriptWindow: {},
+	RustWindow:       {},
+}
+
 func NewIde(winId int, winName string) Ide {
 	windowType := DetermineWindowType(winName)
-	if windowType == PythonWindow {
+	if _, ok := sourceWindowTypes[windowType]; ok {
 		return NewPythonIde(winId, winName)
-	} else {
-		return NewDefaultIde(winId, winName)
 	}
-	return nil
+	return NewDefaultIde(winId, winName)
 }
 
 func WatchWindow(winId int, winName string) {
@@ -1035,6 +1379,7 @@ func main() {
 		log.Fatal(err)
 	}
 	UpdateCurrentSettings(DefaultSettings())
+	UpdateCurrentIdeSettings(DefaultIdeSettings())
 	SetHmacSecret(GenerateHmacSecret())
 	go YcmdForever(argv[1])
 	if IsReady(100 * time.Millisecond) {
@@ -1059,5 +1404,8 @@ func main() {
 		if logEvent.Op == "new" {
 			go WatchWindow(logEvent.ID, logEvent.Name)
 		}
+		if logEvent.Op == "del" {
+			ForgetPreviewWindow(logEvent.ID)
+		}
 	}
 }
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"9fans.net/go/acme"
+)
+
+// The fields of an acme window's ctl file that matter to us. Width is the body's width in pixels, which is the same for
+// every window in a column.
+type AcmeCtl struct {
+	Id          int
+	TagLength   int
+	BodyLength  int
+	IsDirectory bool
+	IsDirty     bool
+	Width       int
+}
+
+func ParseAcmeCtl(ctl string) (*AcmeCtl, error) {
+	fields := strings.Fields(ctl)
+	if len(fields) < 5 {
+		return nil, errors.New(fmt.Sprintf("Invalid ctl: %s", ctl))
+	}
+	numbers := make([]int, 5)
+	for i := range numbers {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid ctl: %s", ctl))
+		}
+		numbers[i] = n
+	}
+	acmeCtl := &AcmeCtl{
+		Id:          numbers[0],
+		TagLength:   numbers[1],
+		BodyLength:  numbers[2],
+		IsDirectory: numbers[3] == 1,
+		IsDirty:     numbers[4] == 1,
+	}
+	if len(fields) > 5 {
+		acmeCtl.Width, _ = strconv.Atoi(fields[5])
+	}
+	return acmeCtl, nil
+}
+
+// A window ycmd may want to know about, with a shadow of its body kept up to date from events.
+type OpenBuffer struct {
+	Id        int
+	Name      string
+	Filetypes []string
+	// Guards the fields below. Watch takes it for every event, so it's never held across acme I/O.
+	lock   sync.Mutex
+	shadow *ShadowBuffer
+	// Set when an event couldn't be applied, so the shadow has to be read again.
+	stale      bool
+	lastSynced time.Time
+	// Counts the body changes seen, so unsavedContents can tell whether any came in while it read ctl.
+	events int
+	// Set when ctl said the window is clean and no change has come in since, so requests can skip it without asking
+	// acme.
+	clean bool
+}
+
+// The source windows being watched, by window id: PythonIde registers every window in sourceWindowTypes. The lock
+// only guards the map; each buffer has its own.
+var openBuffers = map[int]*OpenBuffer{}
+var openBuffersLock sync.Mutex
+
+func RegisterBuffer(id int, name string) {
+	filetypes := FiletypesFor(name)
+	if len(filetypes) == 0 {
+		return
+	}
+	openBuffersLock.Lock()
+	defer openBuffersLock.Unlock()
+	openBuffers[id] = &OpenBuffer{Id: id, Name: name, Filetypes: filetypes, stale: true}
+}
+
+func UnregisterBuffer(id int) {
+	openBuffersLock.Lock()
+	defer openBuffersLock.Unlock()
+	delete(openBuffers, id)
+}
+
+// Register the window again under its new name, e.g. after a jump opened another file in it. The old shadow is
+// dropped, so the next request reads the new body.
+func RenameBuffer(id int, name string) {
+	UnregisterBuffer(id)
+	RegisterBuffer(id, name)
+}
+
+// The ids of the watched windows showing path.
+func BufferIdsFor(path string) []int {
+	openBuffersLock.Lock()
+	defer openBuffersLock.Unlock()
+	var ids []int
+	for id, buffer := range openBuffers {
+		if buffer.Name == path {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// True for events that say the body text changed.
+func IsBodyChangeEvent(e *acme.Event) bool {
+	return e.C2 == 'I' || e.C2 == 'D'
+}
+
+func lookupBuffer(id int) (*OpenBuffer, bool) {
+	openBuffersLock.Lock()
+	defer openBuffersLock.Unlock()
+	buffer, ok := openBuffers[id]
+	return buffer, ok
+}
+
+// Keep the window's shadow in step with an insert or delete in its body.
+func UpdateBufferFromEvent(id int, e *acme.Event) {
+	if !IsBodyChangeEvent(e) {
+		return
+	}
+	buffer, ok := lookupBuffer(id)
+	if !ok {
+		return
+	}
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+	buffer.events++
+	buffer.clean = false
+	if buffer.stale {
+		return
+	}
+	err := buffer.shadow.ApplyEvent(e.C2, e.Q0, e.Q1, e.Text)
+	if err != nil {
+		log.Printf("UpdateBufferFromEvent: %s: %s\n", buffer.Name, err)
+		buffer.stale = true
+	}
+}
+
+func shadowResyncInterval() time.Duration {
+	seconds := 30
+	if settings := GetIdeSettings(); settings != nil && settings.ShadowResyncSeconds > 0 {
+		seconds = settings.ShadowResyncSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Whether the shadow can be used as is for a window in the state ctl describes. A shadow that hasn't been checked
+// against the body for a while is read again in withShadow, so missed events don't go unnoticed for long.
+func (b *OpenBuffer) IsCurrent(ctl *AcmeCtl) bool {
+	return !b.stale && b.shadow != nil && b.shadow.Len() == ctl.BodyLength
+}
+
+// Requests for a window's Watch loop to read the body into its shadow. The shadow is only ever replaced there, between
+// events: a body read anywhere else may already have changes whose events are still on their way, and those would
+// then be applied twice.
+var shadowResyncs = map[int]chan chan error{}
+var shadowResyncsLock sync.Mutex
+
+// How long to wait for Watch to take a resync request before reading the body without it.
+const shadowResyncWait = time.Second
+
+// The channel the window's Watch loop takes resync requests from.
+func ShadowResyncRequests(id int) chan chan error {
+	shadowResyncsLock.Lock()
+	defer shadowResyncsLock.Unlock()
+	requests, ok := shadowResyncs[id]
+	if !ok {
+		requests = make(chan chan error)
+		shadowResyncs[id] = requests
+	}
+	return requests
+}
+
+func ForgetShadowResyncs(id int) {
+	shadowResyncsLock.Lock()
+	defer shadowResyncsLock.Unlock()
+	delete(shadowResyncs, id)
+}
+
+// Read the body into the window's shadow. Only Watch calls it, when no event is waiting, so every change in the body
+// has had its event applied and the events after it are all new. If the body changed while it was read, which ctl
+// shows, the shadow is left alone.
+func ResyncShadow(id int, win *acme.Win) error {
+	buffer, ok := lookupBuffer(id)
+	if !ok {
+		return nil
+	}
+	ctl, err := readAcmeCtl(win)
+	if err != nil {
+		return err
+	}
+	body, err := GetAcmeWindowBody(win)
+	if err != nil {
+		return err
+	}
+	shadow := NewShadowBuffer(body)
+	if shadow.Len() != ctl.BodyLength {
+		return errors.New(fmt.Sprintf("%s changed while it was read", buffer.Name))
+	}
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+	if buffer.IsCurrent(ctl) && shadow.Checksum() != buffer.shadow.Checksum() {
+		log.Printf("Shadow of %s drifted from the body, resyncing\n", buffer.Name)
+	}
+	buffer.shadow = shadow
+	buffer.stale = false
+	buffer.lastSynced = time.Now()
+	return nil
+}
+
+// Have the window's Watch loop resync its shadow. False if nothing took the request, e.g. the window isn't watched.
+func requestShadowResync(id int) (bool, error) {
+	shadowResyncsLock.Lock()
+	requests, ok := shadowResyncs[id]
+	shadowResyncsLock.Unlock()
+	if !ok {
+		return false, nil
+	}
+	done := make(chan error, 1)
+	select {
+	case requests <- done:
+	case <-time.After(shadowResyncWait):
+		return false, nil
+	}
+	return true, <-done
+}
+
+// Call read with an up to date copy of the window body: the shadow, or if that's stale or due a check, the shadow
+// once Watch has read the body again. If Watch doesn't, the body read here is used, but doesn't replace the shadow.
+// read may run with the buffer's lock held, so it mustn't do I/O.
+func (b *OpenBuffer) withShadow(win *acme.Win, ctl *AcmeCtl, read func(shadow *ShadowBuffer) error) error {
+	b.lock.Lock()
+	if b.IsCurrent(ctl) && time.Since(b.lastSynced) < shadowResyncInterval() {
+		defer b.lock.Unlock()
+		return read(b.shadow)
+	}
+	b.lock.Unlock()
+	resynced, err := requestShadowResync(b.Id)
+	if err != nil {
+		log.Printf("Resyncing %s: %s\n", b.Name, err)
+	}
+	if resynced && err == nil {
+		b.lock.Lock()
+		if !b.stale && b.shadow != nil {
+			defer b.lock.Unlock()
+			return read(b.shadow)
+		}
+		b.lock.Unlock()
+	}
+	body, err := GetAcmeWindowBody(win)
+	if err != nil {
+		return err
+	}
+	return read(NewShadowBuffer(body))
+}
+
+func readAcmeCtl(win *acme.Win) (*AcmeCtl, error) {
+	ctlBytes, err := win.ReadAll("ctl")
+	if err != nil {
+		return nil, err
+	}
+	return ParseAcmeCtl(string(ctlBytes))
+}
+
+// The unsaved contents of the buffer, or false if it has none. Acme is only asked when the body changed since it last
+// said the window was clean, so a request doesn't read the ctl of every open window.
+func (b *OpenBuffer) unsavedContents() (string, bool, error) {
+	b.lock.Lock()
+	clean, events := b.clean, b.events
+	b.lock.Unlock()
+	if clean {
+		return "", false, nil
+	}
+	win, err := acme.Open(b.Id, nil)
+	if err != nil {
+		return "", false, err
+	}
+	defer win.CloseFiles()
+	ctl, err := readAcmeCtl(win)
+	if err != nil {
+		return "", false, err
+	}
+	if !ctl.IsDirty {
+		b.lock.Lock()
+		// A change that came in while ctl was read may have made it dirty again.
+		b.clean = b.events == events
+		b.lock.Unlock()
+		return "", false, nil
+	}
+	var contents string
+	err = b.withShadow(win, ctl, func(shadow *ShadowBuffer) error {
+		contents = shadow.String()
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return contents, true, nil
+}
+
+// The body of a watched window and its dot, from the shadow where possible. Falls back to reading the body for
+// windows that aren't registered.
+func AcmeBufferSnapshot(id int, win *acme.Win) (string, *LineAndColumn, *LineAndColumn, error) {
+	err := win.Ctl("addr=dot")
+	if err != nil {
+		return "", nil, nil, err
+	}
+	q0, q1, err := win.ReadAddr()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	buffer, ok := lookupBuffer(id)
+	if !ok {
+		body, err := GetAcmeWindowBody(win)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		start, end, err := GetAcmeWindowSelection(win, body)
+		return body, start, end, err
+	}
+	ctl, err := readAcmeCtl(win)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var body string
+	var start, end *LineAndColumn
+	err = buffer.withShadow(win, ctl, func(shadow *ShadowBuffer) error {
+		if shadow.Len() < q1 || q1 < q0 {
+			return errors.New(fmt.Sprintf("Acme body size is smaller than dot: %d < #%d,#%d", shadow.Len(), q0, q1))
+		}
+		body, start, end = shadow.String(), shadow.LineAndColumn(q0), shadow.LineAndColumn(q1)
+		return nil
+	})
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return body, start, end, nil
+}
+
+// The contents of every dirty source window except the one named exclude, keyed by path, for file_data.
+func DirtyBufferFileData(exclude string) map[string]YcmdFileData {
+	openBuffersLock.Lock()
+	buffers := make([]*OpenBuffer, 0, len(openBuffers))
+	for _, buffer := range openBuffers {
+		if buffer.Name != exclude {
+			buffers = append(buffers, buffer)
+		}
+	}
+	openBuffersLock.Unlock()
+	fileData := map[string]YcmdFileData{}
+	for _, buffer := range buffers {
+		contents, dirty, err := buffer.unsavedContents()
+		if err != nil {
+			// Most likely the window was closed under us.
+			log.Printf("DirtyBufferFileData: %s: %s\n", buffer.Name, err)
+			openBuffersLock.Lock()
+			if openBuffers[buffer.Id] == buffer {
+				delete(openBuffers, buffer.Id)
+			}
+			openBuffersLock.Unlock()
+			continue
+		}
+		if dirty {
+			fileData[buffer.Name] = YcmdFileData{Filetypes: buffer.Filetypes, Contents: contents}
+		}
+	}
+	return fileData
+}
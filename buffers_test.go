@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"9fans.net/go/acme"
+)
+
+func TestParseAcmeCtl(t *testing.T) {
+	ctl, err := ParseAcmeCtl("          3          32        1042           0           1         640 /lib/font/bit/lucsans/euro.8.font 4 ")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	expected := AcmeCtl{Id: 3, TagLength: 32, BodyLength: 1042, IsDirectory: false, IsDirty: true, Width: 640}
+	if *ctl != expected {
+		t.Logf("%+v", ctl)
+		t.Fail()
+	}
+	if _, err := ParseAcmeCtl("3 32"); err == nil {
+		t.Log("Short ctl should be an error")
+		t.Fail()
+	}
+}
+
+func TestOpenBuffer_IsCurrent(t *testing.T) {
+	buffer := &OpenBuffer{Id: 3, Name: "/src/a.h", stale: true}
+	ctl := &AcmeCtl{Id: 3, BodyLength: 10, IsDirty: true}
+	if buffer.IsCurrent(ctl) {
+		t.Log("A buffer that was never read isn't current")
+		t.Fail()
+	}
+	buffer.shadow, buffer.stale = NewShadowBuffer("0123456789"), false
+	if !buffer.IsCurrent(ctl) {
+		t.Log("Nothing changed, the shadow should be used")
+		t.Fail()
+	}
+	ctl.BodyLength = 11
+	if buffer.IsCurrent(ctl) {
+		t.Log("A different length means an event was missed")
+		t.Fail()
+	}
+}
+
+func TestUpdateBufferFromEvent(t *testing.T) {
+	openBuffersLock.Lock()
+	openBuffers[1003] = &OpenBuffer{Id: 1003, Name: "/src/b.py", shadow: NewShadowBuffer("ab\n"), stale: false}
+	openBuffersLock.Unlock()
+	defer UnregisterBuffer(1003)
+	UpdateBufferFromEvent(1003, &acme.Event{C1: 'K', C2: 'I', Q0: 1, Q1: 2, Text: []byte("x")})
+	UpdateBufferFromEvent(1003, &acme.Event{C1: 'M', C2: 'x', Q0: 0, Q1: 2, Text: []byte("ax")})
+	openBuffersLock.Lock()
+	buffer := openBuffers[1003]
+	openBuffersLock.Unlock()
+	if buffer.shadow.String() != "axb\n" || buffer.stale || buffer.events != 1 {
+		t.Logf("%q stale=%t events=%d", buffer.shadow.String(), buffer.stale, buffer.events)
+		t.FailNow()
+	}
+	// Long inserts come without their text.
+	UpdateBufferFromEvent(1003, &acme.Event{C1: 'F', C2: 'I', Q0: 0, Q1: 300})
+	if !buffer.stale {
+		t.Log("An insert without its text should make the shadow stale")
+		t.Fail()
+	}
+}
+
+func TestRegisterBuffer(t *testing.T) {
+	RegisterBuffer(1001, "/src/guide")
+	RegisterBuffer(1002, "/src/a.cpp")
+	defer UnregisterBuffer(1002)
+	openBuffersLock.Lock()
+	_, text := openBuffers[1001]
+	buffer, source := openBuffers[1002]
+	openBuffersLock.Unlock()
+	if text || !source {
+		t.Log("Only windows ycmd understands should be registered")
+		t.FailNow()
+	}
+	if len(buffer.Filetypes) != 1 || buffer.Filetypes[0] != "cpp" {
+		t.Logf("%+v", buffer)
+		t.Fail()
+	}
+}
+
+func TestRenameBuffer(t *testing.T) {
+	RegisterBuffer(1004, "/src/a.cpp")
+	defer UnregisterBuffer(1004)
+	RenameBuffer(1004, "/src/b.py")
+	if ids := BufferIdsFor("/src/a.cpp"); len(ids) != 0 {
+		t.Logf("%+v", ids)
+		t.Fail()
+	}
+	buffer, ok := lookupBuffer(1004)
+	if !ok || buffer.Name != "/src/b.py" || buffer.Filetypes[0] != "python" || !buffer.stale {
+		t.Logf("%+v", buffer)
+		t.Fail()
+	}
+	RenameBuffer(1004, "/src/notes.txt")
+	if _, ok := lookupBuffer(1004); ok {
+		t.Log("A window showing a file ycmd can't use shouldn't stay registered")
+		t.Fail()
+	}
+}
+
+func TestUnsavedContentsOfCleanBuffer(t *testing.T) {
+	buffer := &OpenBuffer{Id: 1004, Name: "/src/c.h", stale: true, clean: true}
+	if _, dirty, err := buffer.unsavedContents(); dirty || err != nil {
+		t.Logf("A buffer known to be clean shouldn't need acme: %t %s", dirty, err)
+		t.Fail()
+	}
+	openBuffersLock.Lock()
+	openBuffers[1004] = buffer
+	openBuffersLock.Unlock()
+	defer UnregisterBuffer(1004)
+	UpdateBufferFromEvent(1004, &acme.Event{C1: 'K', C2: 'I', Q0: 0, Q1: 1, Text: []byte("x")})
+	if buffer.clean {
+		t.Log("Typing should make acme be asked again")
+		t.Fail()
+	}
+}
+
+func TestRequestShadowResync(t *testing.T) {
+	if resynced, err := requestShadowResync(1005); resynced || err != nil {
+		t.Log("Nothing watches 1005, so the caller should read the body itself")
+		t.Fail()
+	}
+	requests := ShadowResyncRequests(1005)
+	defer ForgetShadowResyncs(1005)
+	go func() {
+		done := <-requests
+		done <- errors.New("changed while it was read")
+	}()
+	if resynced, err := requestShadowResync(1005); !resynced || err == nil {
+		t.Logf("The watcher's answer should come back: %t %s", resynced, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ycmd's subcommands for each direction of the call hierarchy.
+var callHierarchySubcommands = map[string]string{
+	"in":  "GoToCallers",
+	"out": "GoToCallees",
+}
+
+func newCallNode(location FileLocation) *TreeNode {
+	label := strings.TrimSpace(location.Description)
+	if label == "" {
+		label = "?"
+	}
+	return &TreeNode{Location: location, Label: label}
+}
+
+// Expanding a node asks ycmd for the callers (or callees) at the node's location.
+func expandCallNode(subcommand string) func(ctx context.Context, view *TreeView, node *TreeNode) error {
+	return func(ctx context.Context, view *TreeView, node *TreeNode) error {
+		ycmdRequest, err := NewLocationYcmdRequest(node.Location, subcommand)
+		if err != nil {
+			return err
+		}
+		blob, err := PostHandler(ctx, "run_completer_command", ycmdRequest)
+		if err != nil {
+			return err
+		}
+		locations, err := DecodeFileLocations(blob)
+		if err != nil {
+			return err
+		}
+		node.Children = make([]*TreeNode, 0, len(locations))
+		for _, location := range locations {
+			node.Children = append(node.Children, newCallNode(location))
+		}
+		return nil
+	}
+}
+
+// Calls shows who calls the symbol at dot; "Calls out" shows what it calls. The tree opens up one level at a time
+// with button 2 in the +Calls window.
+func (p *PythonIde) ShowCallHierarchy(ctx context.Context, args []string) error {
+	direction := "in"
+	if len(args) > 0 {
+		direction = args[0]
+	}
+	subcommand, ok := callHierarchySubcommands[direction]
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown Calls direction: %s, use in or out", direction))
+	}
+	ycmdRequest, err := p.NewYcmdRequest()
+	if err != nil {
+		return err
+	}
+	body := strings.Split(ycmdRequest.FileContents, "\n")
+	root := newCallNode(FileLocation{
+		LineNum:   ycmdRequest.LineNum,
+		ColumnNum: ycmdRequest.ColumnNum,
+		Filepath:  p.Name(),
+	})
+	if ycmdRequest.LineNum >= 1 && ycmdRequest.LineNum <= len(body) {
+		root.Label = strings.TrimSpace(body[ycmdRequest.LineNum-1])
+	}
+	view := &TreeView{
+		Name:   ResultsWindowName(p.Name(), "Calls"),
+		Title:  fmt.Sprintf("%s of %s", subcommand, root.Location.String()),
+		Root:   root,
+		Expand: expandCallNode(subcommand),
+	}
+	err = view.Expand(ctx, view, root)
+	if err != nil {
+		return err
+	}
+	root.Expanded = true
+	return ShowTree(view, 0)
+}
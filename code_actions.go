@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"9fans.net/go/acme"
+)
+
+// The FixIts listed in a +Fix window, and the request that produced them, for resolving them later.
+type FixItView struct {
+	Request *YcmdRequest
+	FixIts  []FixIt
+}
+
+var fixItViews = map[string]*FixItView{}
+var fixItViewsLock sync.Mutex
+
+// Lines before the first FixIt: the title and a blank line.
+const fixItHeaderLines = 2
+
+func fixItLabel(fixIt *FixIt) string {
+	label := strings.TrimSpace(fixIt.Text)
+	if idx := strings.Index(label, "\n"); idx >= 0 {
+		label = label[:idx]
+	}
+	if label == "" {
+		label = fixIt.Kind
+	}
+	if label == "" {
+		label = "FixIt"
+	}
+	return label
+}
+
+func FormatFixIts(title string, fixIts []FixIt) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", title)
+	for i := range fixIts {
+		fmt.Fprintf(&b, "%d\t%s\t%s\n", i+1, fixItLabel(&fixIts[i]), fixIts[i].Location.String())
+	}
+	return b.String()
+}
+
+// List the FixIts and code actions ycmd has for dot, or for the selection if there is one, in the +Fix window.
+func (p *PythonIde) ShowFixIts(ctx context.Context) error {
+	ycmdRequest, err := p.NewYcmdRequest("FixIt")
+	if err != nil {
+		return err
+	}
+	blob, err := PostHandler(ctx, "run_completer_command", ycmdRequest)
+	if err != nil {
+		return err
+	}
+	fixIts, err := DecodeFixIts(blob)
+	if err != nil {
+		return err
+	}
+	where := fmt.Sprintf("%s:%d:%d", p.Name(), ycmdRequest.LineNum, ycmdRequest.ColumnNum)
+	if ycmdRequest.Range != nil {
+		where = fmt.Sprintf("%s-%d:%d", where, ycmdRequest.Range.End.LineNum, ycmdRequest.Range.End.ColumnNum)
+	}
+	if len(fixIts) == 0 {
+		return errors.New(fmt.Sprintf("no FixIts for %s", where))
+	}
+	windowName := ResultsWindowName(p.Name(), "Fix")
+	fixItViewsLock.Lock()
+	fixItViews[windowName] = &FixItView{Request: ycmdRequest, FixIts: fixIts}
+	fixItViewsLock.Unlock()
+	return AcmeReplaceWindowBody(windowName, FormatFixIts(fmt.Sprintf("FixIts for %s", where), fixIts))
+}
+
+// Code actions often come back without their edits, which ycmd works out on request.
+func ResolveFixIt(ctx context.Context, ycmdRequest *YcmdRequest, fixIt *FixIt) (*FixIt, error) {
+	if !fixIt.Resolve {
+		return fixIt, nil
+	}
+	resolveRequest := *ycmdRequest
+	resolveRequest.CommandArguments = nil
+	resolveRequest.ExtraFields = map[string]interface{}{"fixit": fixIt.Raw}
+	blob, err := PostHandler(ctx, "resolve_fixit", &resolveRequest)
+	if err != nil {
+		return nil, err
+	}
+	fixIts, err := DecodeFixIts(blob)
+	if err != nil {
+		return nil, err
+	}
+	if len(fixIts) == 0 {
+		return nil, errors.New(fmt.Sprintf("%s: nothing to apply", fixItLabel(fixIt)))
+	}
+	return &fixIts[0], nil
+}
+
+// Button 2 or 3 on a FixIt in a +Fix window applies it. The list is cleared afterwards, since the other FixIts were
+// worked out for the text before the change. Returns false if the event should be passed back to acme.
+func HandleFixItClick(ide Ide, win *acme.Win, e *acme.Event) (*IdeJob, error) {
+	area, err := WhichAcmeArea(e)
+	if err != nil || area != AcmeAreaBody {
+		return nil, nil
+	}
+	if _, err := WhichAcmeButton(e); err != nil {
+		return nil, nil
+	}
+	winName, err := AcmeWinName(win)
+	if err != nil {
+		return nil, nil
+	}
+	fixItViewsLock.Lock()
+	view, ok := fixItViews[winName]
+	fixItViewsLock.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	body, err := win.ReadAll("body")
+	if err != nil {
+		return nil, err
+	}
+	line, _ := LineAndColumnOfOffset([]rune(string(body)), e.OrigQ0)
+	row := line - fixItHeaderLines - 1
+	if row < 0 || row >= len(view.FixIts) {
+		return nil, nil
+	}
+	return &IdeJob{Name: "FixIt", Run: func(ctx context.Context) error {
+		fixIt, err := ResolveFixIt(ctx, view.Request, &view.FixIts[row])
+		if err != nil {
+			return err
+		}
+		err = ApplyFixIt(fixIt)
+		if err != nil {
+			return err
+		}
+		fixItViewsLock.Lock()
+		delete(fixItViews, winName)
+		fixItViewsLock.Unlock()
+		return AcmeReplaceWindowBody(winName, fmt.Sprintf("Applied: %s\n", fixItLabel(fixIt)))
+	}}, nil
+}
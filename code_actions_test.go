@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const FixItListJson = `{"fixits": [
+	{"text": "Extract method", "kind": "refactor.extract", "resolve": true, "command": {"id": 3},
+	 "location": {"filepath": "/tmp/wtf.py", "line_num": 12, "column_num": 5}, "chunks": []},
+	{"text": "", "kind": "quickfix", "location": {"filepath": "/tmp/wtf.py", "line_num": 14, "column_num": 1},
+	 "chunks": []}
+]}`
+
+func TestFormatFixIts(t *testing.T) {
+	fixIts, err := DecodeFixIts([]byte(FixItListJson))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if !fixIts[0].Resolve || !strings.Contains(string(fixIts[0].Raw), `"command"`) {
+		t.Logf("Expected the raw FixIt to be kept for resolving: %+v\n", fixIts[0])
+		t.Fail()
+	}
+	expected := strings.Join([]string{
+		"FixIts for /tmp/wtf.py:12:5-14:1",
+		"",
+		"1\tExtract method\t/tmp/wtf.py:12:5",
+		"2\tquickfix\t/tmp/wtf.py:14:1",
+		"",
+	}, "\n")
+	actual := FormatFixIts("FixIts for /tmp/wtf.py:12:5-14:1", fixIts)
+	if actual != expected {
+		t.Logf("Expected:\n%s\nbut received:\n%s\n", expected, actual)
+		t.Fail()
+	}
+}
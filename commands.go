@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"9fans.net/go/acme"
+)
+
+// How long to wait for ycmd when request_timeouts doesn't say.
+const DefaultRequestTimeout = 10 * time.Second
+
+// The key used in request_timeouts, e.g. "run_completer_command:GoToReferences" for one subcommand.
+func RequestTimeoutKey(handler, subcommand string) string {
+	if subcommand == "" {
+		return handler
+	}
+	return fmt.Sprintf("%s:%s", handler, subcommand)
+}
+
+// Look up the timeout for a request. A "handler:Subcommand" entry wins over a "handler" entry, which wins over
+// "default".
+func RequestTimeoutFor(settings *IdeSettings, handler, subcommand string) time.Duration {
+	if settings == nil {
+		return DefaultRequestTimeout
+	}
+	for _, key := range []string{RequestTimeoutKey(handler, subcommand), handler, "default"} {
+		if seconds, ok := settings.RequestTimeouts[key]; ok && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+	}
+	return DefaultRequestTimeout
+}
+
+func CurrentRequestTimeout(handler, subcommand string) time.Duration {
+	return RequestTimeoutFor(GetIdeSettings(), handler, subcommand)
+}
+
+// Say why a request failed when it's because it was cancelled or took too long, rather than the bare HTTP error.
+func requestError(ctx context.Context, handler string, timeout time.Duration, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return errors.New(fmt.Sprintf("%s: no answer from ycmd after %s", handler, timeout))
+	case context.Canceled:
+		return context.Canceled
+	}
+	return err
+}
+
+// A piece of work for ycmd queued on a window: an IDE command, or something an event set off such as formatting before
+// Put. Stop drops queued jobs unless they're marked Keep, like Put, which has to reach acme either way.
+type IdeJob struct {
+	Name string
+	Keep bool
+	Run  func(ctx context.Context) error
+}
+
+// The job running in a window, so Stop or a second click can cancel it.
+type RunningCommand struct {
+	Command string
+	cancel  context.CancelFunc
+}
+
+// Each window runs its jobs one at a time, in the order they were queued, on its own goroutine. The Watch loop only
+// queues them, so typing and other events keep flowing back to acme while ycmd thinks.
+type commandWorker struct {
+	ide     Ide
+	win     *acme.Win
+	queue   []*IdeJob
+	running *RunningCommand
+}
+
+var commandWorkers = map[int]*commandWorker{}
+var commandWorkersLock sync.Mutex
+
+// Numbers each busy status taken under commandWorkersLock, so one written to the tag late can't replace a newer one.
+var busyStatusSeq int
+
+// Cancel whatever is running in the window. Returns the command that was cancelled, if any.
+func CancelIdeCommand(winId int) string {
+	commandWorkersLock.Lock()
+	defer commandWorkersLock.Unlock()
+	worker, ok := commandWorkers[winId]
+	if !ok || worker.running == nil {
+		return ""
+	}
+	worker.running.cancel()
+	return worker.running.Command
+}
+
+// The busy indicator for a worker, e.g. "Busy:Goto+2" while Goto runs with two more jobs queued.
+func (w *commandWorker) status() string {
+	if w.running == nil {
+		return ""
+	}
+	status := fmt.Sprintf("Busy:%s", w.running.Command)
+	if len(w.queue) > 0 {
+		status = fmt.Sprintf("%s+%d", status, len(w.queue))
+	}
+	return status
+}
+
+// A busy status to show once commandWorkersLock is released, which it has to be held to take.
+type busyStatus struct {
+	seq    int
+	status string
+}
+
+func (w *commandWorker) takeStatus() busyStatus {
+	busyStatusSeq++
+	return busyStatus{seq: busyStatusSeq, status: w.status()}
+}
+
+// Write a status taken with takeStatus to the tag. Called without commandWorkersLock, so a slow tag doesn't hold up
+// the other windows.
+func (w *commandWorker) showStatus(status busyStatus) {
+	if w.win == nil {
+		return
+	}
+	err := setAcmeBusyStatus(w.ide.Id(), w.win, status)
+	if err != nil {
+		log.Printf("Error showing status of %s: %s\n", w.ide.Name(), err)
+	}
+}
+
+func (w *commandWorker) run() {
+	for {
+		commandWorkersLock.Lock()
+		if len(w.queue) == 0 {
+			w.running = nil
+			status := w.takeStatus()
+			delete(commandWorkers, w.ide.Id())
+			commandWorkersLock.Unlock()
+			w.showStatus(status)
+			return
+		}
+		job := w.queue[0]
+		w.queue = w.queue[1:]
+		ctx, cancel := context.WithCancel(context.Background())
+		w.running = &RunningCommand{Command: job.Name, cancel: cancel}
+		status := w.takeStatus()
+		commandWorkersLock.Unlock()
+		w.showStatus(status)
+
+		err := RunWithExtraConf(ctx, w.ide, w.win, job)
+		if ctx.Err() == context.Canceled {
+			log.Printf("%s: %s cancelled\n", w.ide.Name(), job.Name)
+			err = AcmeWriteToErrors(w.ide.Id(), w.ide.Name(), fmt.Sprintf("%s: cancelled\n", job.Name))
+		}
+		cancel()
+		if err != nil {
+			ReportError(w.ide, err)
+		}
+	}
+}
+
+// Queue a job on the window's worker, starting the worker if it's idle.
+func QueueIdeJob(ide Ide, win *acme.Win, job *IdeJob) {
+	commandWorkersLock.Lock()
+	worker, ok := commandWorkers[ide.Id()]
+	if !ok {
+		worker = &commandWorker{ide: ide, win: win}
+		commandWorkers[ide.Id()] = worker
+	}
+	worker.queue = append(worker.queue, job)
+	if worker.running == nil {
+		// Mark it busy now, so a second click can't start a second worker.
+		worker.running = &RunningCommand{Command: job.Name, cancel: func() {}}
+		go worker.run()
+	}
+	status := worker.takeStatus()
+	commandWorkersLock.Unlock()
+	worker.showStatus(status)
+}
+
+// Queue an IDE command. Stop cancels the running job and drops the queued ones, and clicking the command that's running
+// again cancels it. Anything else waits its turn.
+func QueueIdeCommand(ide Ide, win *acme.Win, i *IdeCommand, handle func(ctx context.Context, i *IdeCommand) error) {
+	commandWorkersLock.Lock()
+	worker, ok := commandWorkers[ide.Id()]
+	if ok && i.Command == "Stop" {
+		kept := worker.queue[:0]
+		for _, job := range worker.queue {
+			if job.Keep {
+				kept = append(kept, job)
+			}
+		}
+		worker.queue = kept
+		worker.running.cancel()
+		status := worker.takeStatus()
+		commandWorkersLock.Unlock()
+		worker.showStatus(status)
+		return
+	}
+	if ok && len(worker.queue) == 0 && worker.running.Command == i.Command {
+		worker.running.cancel()
+		commandWorkersLock.Unlock()
+		return
+	}
+	commandWorkersLock.Unlock()
+	if i.Command == "Stop" {
+		return
+	}
+	QueueIdeJob(ide, win, &IdeJob{Name: i.Command, Run: func(ctx context.Context) error { return handle(ctx, i) }})
+}
+
+var busyStatusPattern = regexp.MustCompile(`\s*\bBusy:\S*`)
+
+// The user part of a tag (after the "|") with status replacing whatever matched pattern. An empty status removes it.
+// A status that wasn't there yet is only added at the end, so it can be appended without touching the rest.
+func TagWithStatus(userTag string, pattern *regexp.Regexp, status string) string {
+	userTag = pattern.ReplaceAllString(userTag, "")
+	if status == "" {
+		return userTag
+	}
+	if strings.HasSuffix(userTag, " ") {
+		return userTag + status
+	}
+	return fmt.Sprintf("%s %s", userTag, status)
+}
+
+// Different goroutines keep different statuses in the same tag, so only one rewrites a window's tag at a time. Each
+// window has its own lock, so a tag acme is slow to write only holds up statuses for that window.
+type windowTag struct {
+	lock sync.Mutex
+	// The busy status last written.
+	busySeq int
+}
+
+var windowTags = map[int]*windowTag{}
+var windowTagsLock sync.Mutex
+
+func windowTagFor(id int) *windowTag {
+	windowTagsLock.Lock()
+	defer windowTagsLock.Unlock()
+	tag, ok := windowTags[id]
+	if !ok {
+		tag = &windowTag{}
+		windowTags[id] = tag
+	}
+	return tag
+}
+
+func ForgetWindowTag(id int) {
+	windowTagsLock.Lock()
+	defer windowTagsLock.Unlock()
+	delete(windowTags, id)
+}
+
+// Whether a busy status is newer than the one last written, in which case it becomes the last one. Called with the
+// tag's lock held.
+func (t *windowTag) claimBusyStatus(seq int) bool {
+	if seq < t.busySeq {
+		return false
+	}
+	t.busySeq = seq
+	return true
+}
+
+func setAcmeBusyStatus(id int, win *acme.Win, status busyStatus) error {
+	tag := windowTagFor(id)
+	tag.lock.Lock()
+	defer tag.lock.Unlock()
+	if !tag.claimBusyStatus(status.seq) {
+		return nil
+	}
+	return setAcmeTagStatus(win, busyStatusPattern, status.status)
+}
+
+// How many times to try rewriting a tag the user keeps typing in before giving up until the next status.
+const tagStatusAttempts = 3
+
+// Show status, such as "Busy:Goto", at the end of the window's tag, replacing the earlier status that matches pattern
+// and leaving the rest of the user's text alone. acme can't address text in a tag, only append to it or clear it, so
+// a new status is appended, and the tag is only cleared and rewritten to change or remove one, and then only if
+// nothing was typed since it was read.
+func SetAcmeTagStatus(id int, win *acme.Win, pattern *regexp.Regexp, status string) error {
+	tag := windowTagFor(id)
+	tag.lock.Lock()
+	defer tag.lock.Unlock()
+	return setAcmeTagStatus(win, pattern, status)
+}
+
+func setAcmeTagStatus(win *acme.Win, pattern *regexp.Regexp, status string) error {
+	for attempt := 0; attempt < tagStatusAttempts; attempt++ {
+		tag, err := win.ReadAll("tag")
+		if err != nil {
+			return err
+		}
+		idx := strings.Index(string(tag), "|")
+		if idx < 0 {
+			return nil
+		}
+		userTag := string(tag[idx+1:])
+		newUserTag := TagWithStatus(userTag, pattern, status)
+		if newUserTag == userTag {
+			return nil
+		}
+		if strings.HasPrefix(newUserTag, userTag) {
+			_, err = win.Write("tag", []byte(newUserTag[len(userTag):]))
+			return err
+		}
+		again, err := win.ReadAll("tag")
+		if err != nil {
+			return err
+		}
+		if string(again) != string(tag) {
+			continue
+		}
+		err = win.Ctl("cleartag")
+		if err != nil {
+			return err
+		}
+		_, err = win.Write("tag", []byte(newUserTag))
+		return err
+	}
+	return errors.New("the tag kept changing")
+}
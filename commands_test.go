@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeoutFor(t *testing.T) {
+	settings := &IdeSettings{RequestTimeouts: map[string]float64{
+		"default":                              10,
+		"run_completer_command":                20,
+		"run_completer_command:GoToReferences": 60,
+		"signature_help":                       0.5,
+	}}
+	cases := []struct {
+		Handler    string
+		Subcommand string
+		Expected   time.Duration
+	}{
+		{"run_completer_command", "GoToReferences", 60 * time.Second},
+		{"run_completer_command", "GoTo", 20 * time.Second},
+		{"signature_help", "", 500 * time.Millisecond},
+		{"inlay_hints", "", 10 * time.Second},
+	}
+	for _, c := range cases {
+		if actual := RequestTimeoutFor(settings, c.Handler, c.Subcommand); actual != c.Expected {
+			t.Logf("%s: expected %s, got %s", RequestTimeoutKey(c.Handler, c.Subcommand), c.Expected, actual)
+			t.Fail()
+		}
+	}
+	if RequestTimeoutFor(nil, "ready", "") != DefaultRequestTimeout {
+		t.Log("No settings should mean the default timeout")
+		t.Fail()
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	err := errors.New("connection refused")
+	if requestError(context.Background(), "ready", time.Second, err) != err {
+		t.Log("Other errors should be passed through")
+		t.Fail()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	timedOut := requestError(ctx, "run_completer_command", 2*time.Second, err)
+	if !strings.Contains(timedOut.Error(), "no answer from ycmd after 2s") {
+		t.Log(timedOut)
+		t.Fail()
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if requestError(ctx, "ready", time.Second, err) != context.Canceled {
+		t.Log("Cancelled requests should say so")
+		t.Fail()
+	}
+}
+
+type fakeIde struct {
+	id int
+}
+
+func (f *fakeIde) Setup() error       { return nil }
+func (f *fakeIde) Watch()             {}
+func (f *fakeIde) Teardown()          {}
+func (f *fakeIde) Name() string       { return "/src/fake.py" }
+func (f *fakeIde) Id() int            { return f.id }
+func (f *fakeIde) Rename(name string) {}
+
+func workerStatus(id int) string {
+	commandWorkersLock.Lock()
+	defer commandWorkersLock.Unlock()
+	worker, ok := commandWorkers[id]
+	if !ok {
+		return ""
+	}
+	return worker.status()
+}
+
+func TestQueueIdeJob(t *testing.T) {
+	ide := &fakeIde{id: 2001}
+	release := make(chan struct{})
+	done := make(chan struct{})
+	var order []string
+	var orderLock sync.Mutex
+	job := func(name string) *IdeJob {
+		return &IdeJob{Name: name, Run: func(ctx context.Context) error {
+			if name == "a" {
+				<-release
+			}
+			orderLock.Lock()
+			order = append(order, name)
+			orderLock.Unlock()
+			if name == "c" {
+				close(done)
+			}
+			return nil
+		}}
+	}
+	QueueIdeJob(ide, nil, job("a"))
+	QueueIdeJob(ide, nil, job("b"))
+	QueueIdeJob(ide, nil, job("c"))
+	for i := 0; i < 100 && workerStatus(ide.Id()) != "Busy:a+2"; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if status := workerStatus(ide.Id()); status != "Busy:a+2" {
+		t.Logf("Status: %q", status)
+		t.Fail()
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Log("Jobs didn't finish")
+		t.FailNow()
+	}
+	orderLock.Lock()
+	defer orderLock.Unlock()
+	if strings.Join(order, "") != "abc" {
+		t.Logf("Jobs ran out of order: %v", order)
+		t.Fail()
+	}
+}
+
+func TestCancelIdeCommand(t *testing.T) {
+	ide := &fakeIde{id: 2002}
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	QueueIdeJob(ide, nil, &IdeJob{Name: "Goto", Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil
+	}})
+	<-started
+	if command := CancelIdeCommand(ide.Id()); command != "Goto" {
+		t.Logf("Cancelled %q", command)
+		t.Fail()
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Log("The job's context should be cancelled")
+		t.Fail()
+	}
+	if CancelIdeCommand(2003) != "" {
+		t.Log("Nothing runs in 2003")
+		t.Fail()
+	}
+}
+
+func TestTagWithStatus(t *testing.T) {
+	userTag := " Goto Nav Stop Busy:Goto+1 mk"
+	if actual := TagWithStatus(userTag, busyStatusPattern, "Busy:Fix"); actual != " Goto Nav Stop mk Busy:Fix" {
+		t.Logf("%q", actual)
+		t.Fail()
+	}
+	if actual := TagWithStatus(userTag, busyStatusPattern, ""); actual != " Goto Nav Stop mk" {
+		t.Logf("%q", actual)
+		t.Fail()
+	}
+	// A new status goes on the end, so it can be appended without rewriting the tag.
+	for _, userTag := range []string{" Goto Nav Stop ", " Goto Nav Stop"} {
+		actual := TagWithStatus(userTag, busyStatusPattern, "Busy:Fix")
+		if !strings.HasPrefix(actual, userTag) || !strings.HasSuffix(actual, " Busy:Fix") {
+			t.Logf("%q", actual)
+			t.Fail()
+		}
+	}
+}
+
+func TestClaimBusyStatus(t *testing.T) {
+	tag := &windowTag{}
+	if !tag.claimBusyStatus(2) {
+		t.Log("The first status should be written")
+		t.Fail()
+	}
+	// A worker that took its status first but got to the tag last mustn't undo the newer one.
+	if tag.claimBusyStatus(1) {
+		t.Log("An older status shouldn't be written")
+		t.Fail()
+	}
+	if !tag.claimBusyStatus(3) {
+		t.Log("A newer status should be written")
+		t.Fail()
+	}
+}
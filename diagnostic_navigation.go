@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"9fans.net/go/acme"
+)
+
+func diagnosticBefore(a *Diagnostic, line, column int) bool {
+	return a.Location.LineNum < line || a.Location.LineNum == line && a.Location.ColumnNum < column
+}
+
+func diagnosticAfter(a *Diagnostic, line, column int) bool {
+	return a.Location.LineNum > line || a.Location.LineNum == line && a.Location.ColumnNum > column
+}
+
+// The index of the first diagnostic after line and column, or going backwards the last one before, wrapping around
+// the ends of the file. diagnostics must be sorted. -1 if there are none.
+func NextDiagnosticIndex(diagnostics []Diagnostic, line, column int, forward bool) int {
+	if len(diagnostics) == 0 {
+		return -1
+	}
+	if forward {
+		for i := range diagnostics {
+			if diagnosticAfter(&diagnostics[i], line, column) {
+				return i
+			}
+		}
+		return 0
+	}
+	for i := len(diagnostics) - 1; i >= 0; i-- {
+		if diagnosticBefore(&diagnostics[i], line, column) {
+			return i
+		}
+	}
+	return len(diagnostics) - 1
+}
+
+// The rune offset of a 1-based line and byte column in body, for acme addresses.
+func RuneOffsetOf(body string, lineNum, columnNum int) int {
+	return utf8.RuneCountInString(body[:byteOffsetOf(strings.Split(body, "\n"), lineNum, columnNum)])
+}
+
+// The diagnostics located in path itself. A file's diagnostics can include ones in the headers it includes.
+func DiagnosticsIn(path string, diagnostics []Diagnostic) []Diagnostic {
+	var in []Diagnostic
+	for i := range diagnostics {
+		if diagnostics[i].Location.Filepath == path {
+			in = append(in, diagnostics[i])
+		}
+	}
+	return in
+}
+
+// Next and Prev move dot to the next or previous diagnostic in the window, and say what it is in +Errors.
+func (p *PythonIde) JumpToDiagnostic(forward bool) error {
+	diagnostics := DiagnosticsIn(p.Name(), DiagnosticsFor(p.Name()))
+	if len(diagnostics) == 0 {
+		return errors.New(fmt.Sprintf("no diagnostics for %s", p.Name()))
+	}
+	body, dot, _, err := AcmeBufferSnapshot(p.Id(), p.acmeWin)
+	if err != nil {
+		return err
+	}
+	i := NextDiagnosticIndex(diagnostics, dot.Line, dot.Column, forward)
+	diagnostic := diagnostics[i]
+	err = p.acmeWin.Addr("#%d", RuneOffsetOf(body, diagnostic.Location.LineNum, diagnostic.Location.ColumnNum))
+	if err != nil {
+		return err
+	}
+	p.acmeWin.Ctl("dot=addr")
+	p.acmeWin.Ctl("show")
+	return p.WriteToErrors(fmt.Sprintf("%d/%d %s\n", i+1, len(diagnostics), diagnostic.String()))
+}
+
+var diagnosticCounterPattern = regexp.MustCompile(`\s*\bE:\d+ W:\d+`)
+
+// The counter shown in the tag, e.g. "E:3 W:7".
+func DiagnosticCounter(diagnostics []Diagnostic) string {
+	errorCount, warningCount := CountDiagnostics(diagnostics)
+	return fmt.Sprintf("E:%d W:%d", errorCount, warningCount)
+}
+
+// The counter for the tag of a window showing path. Like Next and Prev, it leaves out diagnostics located in other
+// files, such as included headers.
+func FileDiagnosticCounter(path string, diagnostics []Diagnostic) string {
+	return DiagnosticCounter(DiagnosticsIn(path, diagnostics))
+}
+
+// Keep the counter in the tag of each window showing path up to date.
+func UpdateDiagnosticCounter(path string, diagnostics []Diagnostic) {
+	counter := FileDiagnosticCounter(path, diagnostics)
+	for _, id := range BufferIdsFor(path) {
+		win, err := acme.Open(id, nil)
+		if err != nil {
+			continue
+		}
+		err = SetAcmeTagStatus(id, win, diagnosticCounterPattern, counter)
+		win.CloseFiles()
+		if err != nil {
+			log.Printf("Error updating diagnostic counter of %s: %s\n", path, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNextDiagnosticIndex(t *testing.T) {
+	diagnostics, _ := DecodeDiagnostics([]byte(someDiagnostics))
+	SortDiagnostics(diagnostics)
+	cases := []struct {
+		line, column int
+		forward      bool
+		expected     int
+	}{
+		{1, 1, true, 0},
+		{3, 5, true, 1},
+		{7, 9, true, 0},
+		{9, 1, false, 1},
+		{7, 9, false, 0},
+		{3, 5, false, 1},
+	}
+	for _, c := range cases {
+		if actual := NextDiagnosticIndex(diagnostics, c.line, c.column, c.forward); actual != c.expected {
+			t.Logf("%+v: got %d", c, actual)
+			t.Fail()
+		}
+	}
+	if NextDiagnosticIndex(nil, 1, 1, true) != -1 {
+		t.Fail()
+	}
+}
+
+func TestRuneOffsetOf(t *testing.T) {
+	body := "héllo\nwörld x\n"
+	// "x" is at byte column 8 on line 2, after 6 runes of line 1 and 6 of line 2.
+	if actual := RuneOffsetOf(body, 2, 8); actual != 12 {
+		t.Logf("got %d", actual)
+		t.Fail()
+	}
+}
+
+func TestDiagnosticCounter(t *testing.T) {
+	diagnostics, _ := DecodeDiagnostics([]byte(someDiagnostics))
+	counter := DiagnosticCounter(diagnostics)
+	if counter != "E:1 W:1" {
+		t.Log(counter)
+		t.Fail()
+	}
+	userTag := " Goto Next Prev E:3 W:7 mk Busy:Goto"
+	expected := " Goto Next Prev mk Busy:Goto E:1 W:1"
+	if actual := TagWithStatus(userTag, diagnosticCounterPattern, counter); actual != expected {
+		t.Log(actual)
+		t.Fail()
+	}
+}
+
+func TestDiagnosticsIn(t *testing.T) {
+	diagnostics, _ := DecodeDiagnostics([]byte(someDiagnostics))
+	diagnostics = append(diagnostics, Diagnostic{
+		Location: FileLocation{LineNum: 1, ColumnNum: 1, Filepath: "/src/a.h"}, Kind: "ERROR", Text: "in included file"})
+	in := DiagnosticsIn("/src/a.cpp", diagnostics)
+	if len(in) != 2 {
+		t.Logf("%+v", in)
+		t.Fail()
+	}
+	for i := range in {
+		if in[i].Location.Filepath != "/src/a.cpp" {
+			t.Logf("%+v", in[i])
+			t.Fail()
+		}
+	}
+	// The tag counts what Next and Prev can visit.
+	if counter := FileDiagnosticCounter("/src/a.cpp", diagnostics); counter != "E:1 W:1" {
+		t.Log(counter)
+		t.Fail()
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DiagnosticRange struct {
+	Start FileLocation `json:"start"`
+	End   FileLocation `json:"end"`
+}
+
+// An error or warning from ycmd, as returned by FileReadyToParse or pushed through receive_messages.
+type Diagnostic struct {
+	Location       FileLocation      `json:"location"`
+	LocationExtent DiagnosticRange   `json:"location_extent"`
+	Ranges         []DiagnosticRange `json:"ranges"`
+	Text           string            `json:"text"`
+	Kind           string            `json:"kind"`
+	FixItAvailable bool              `json:"fixit_available"`
+}
+
+func (d *Diagnostic) IsError() bool {
+	return d.Kind == "ERROR"
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s\t%s: %s", d.Location.String(), strings.ToLower(d.Kind), d.Text)
+}
+
+// Decode a list of diagnostics. Anything else, like the empty reply completers without diagnostics give, is no
+// diagnostics.
+func DecodeDiagnostics(blob []byte) ([]Diagnostic, bool) {
+	var diagnostics []Diagnostic
+	err := json.Unmarshal(blob, &diagnostics)
+	if err != nil {
+		return nil, false
+	}
+	return diagnostics, true
+}
+
+// Sort by position, so Next and Prev and the views go down the file.
+func SortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Location, diagnostics[j].Location
+		if a.LineNum != b.LineNum {
+			return a.LineNum < b.LineNum
+		}
+		return a.ColumnNum < b.ColumnNum
+	})
+}
+
+func CountDiagnostics(diagnostics []Diagnostic) (int, int) {
+	errorCount, warningCount := 0, 0
+	for i := range diagnostics {
+		if diagnostics[i].IsError() {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+	return errorCount, warningCount
+}
+
+// The latest diagnostics for each file, by path.
+var diagnosticsByFile = map[string][]Diagnostic{}
+var diagnosticsByFileLock sync.Mutex
+
+// Called with the new diagnostics for a file whenever they change, to update whatever shows them.
+var DiagnosticsHandlers = []func(path string, diagnostics []Diagnostic){
+	RefreshDiagnosticsView,
+	UpdateDiagnosticCounter,
+	RefreshProblemsViews,
+	ReportErrorsAfterPut,
+}
+
+func SetDiagnostics(path string, diagnostics []Diagnostic) {
+	sorted := make([]Diagnostic, len(diagnostics))
+	copy(sorted, diagnostics)
+	SortDiagnostics(sorted)
+	diagnosticsByFileLock.Lock()
+	if len(sorted) == 0 {
+		delete(diagnosticsByFile, path)
+	} else {
+		diagnosticsByFile[path] = sorted
+	}
+	diagnosticsByFileLock.Unlock()
+	for _, handler := range DiagnosticsHandlers {
+		handler(path, sorted)
+	}
+}
+
+// Nothing shows diagnostics for files that aren't open, so drop them once the last window showing path is gone.
+func ForgetClosedFileDiagnostics(path string) {
+	if len(BufferIdsFor(path)) == 0 && len(DiagnosticsFor(path)) > 0 {
+		SetDiagnostics(path, nil)
+	}
+}
+
+// The diagnostics for path. The slice is shared and must not be modified.
+func DiagnosticsFor(path string) []Diagnostic {
+	diagnosticsByFileLock.Lock()
+	defer diagnosticsByFileLock.Unlock()
+	return diagnosticsByFile[path]
+}
+
+func pluralise(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+func FormatDiagnostics(path string, diagnostics []Diagnostic) string {
+	errorCount, warningCount := CountDiagnostics(diagnostics)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Diagnostics for %s: %s, %s\n\n", path, pluralise(errorCount, "error"), pluralise(warningCount, "warning"))
+	for i := range diagnostics {
+		fmt.Fprintf(&b, "%s\n", diagnostics[i].String())
+	}
+	return b.String()
+}
+
+func DiagnosticsWindowName(path string) string {
+	return path + "+Diag"
+}
+
+// Keep the file's +Diag window current, if the user has it open.
+func RefreshDiagnosticsView(path string, diagnostics []Diagnostic) {
+	windowName := DiagnosticsWindowName(path)
+	isOpen, err := AcmeFilepathIsAlreadyOpen(windowName)
+	if err != nil || !isOpen {
+		return
+	}
+	err = AcmeReplaceWindowBody(windowName, FormatDiagnostics(path, diagnostics))
+	if err != nil {
+		log.Printf("Error refreshing %s: %s\n", windowName, err)
+	}
+}
+
+// The fields an event notification carries besides the file. When ycmd collects identifiers from tag files, the
+// window's tag files go along, and when it seeds identifiers with syntax, the filetype's keywords do, both for the
+// identifier completer. BufferVisit also carries the user's snippets, when use_ultisnips_completer is on.
+func (p *PythonIde) EventNotificationFields(eventName string) map[string]interface{} {
+	fields := map[string]interface{}{"event_name": eventName}
+	if CollectsIdentifiersFromTagsFiles() {
+		if tagFiles := p.TagFiles(); len(tagFiles) > 0 {
+			fields["tag_files"] = tagFiles
+		}
+	}
+	if filetypes := p.Filetypes(); len(filetypes) > 0 && SendsSyntaxKeywords(eventName) {
+		fields["syntax_keywords"] = SyntaxKeywordsFor(filetypes[0])
+	}
+	if filetypes := p.Filetypes(); len(filetypes) > 0 && eventName == "BufferVisit" && UsesUltiSnipsCompleter() {
+		if snippets := SnippetsFor(filetypes[0]); len(snippets) > 0 {
+			fields["ultisnips_snippets"] = UltiSnipsSnippets(snippets)
+		}
+	}
+	return fields
+}
+
+// Tell ycmd about the window, e.g. FileReadyToParse, and return its reply.
+func (p *PythonIde) SendEventNotification(ctx context.Context, eventName string) ([]byte, error) {
+	ycmdRequest, err := p.NewYcmdRequest()
+	if err != nil {
+		return nil, err
+	}
+	ycmdRequest.ExtraFields = p.EventNotificationFields(eventName)
+	return PostHandler(ctx, "event_notification", ycmdRequest)
+}
+
+// Have ycmd parse the window and record the diagnostics it replies with. Completers built on language servers reply
+// with nothing and push theirs through receive_messages instead.
+func (p *PythonIde) RefreshDiagnostics(ctx context.Context) error {
+	blob, err := p.SendEventNotification(ctx, "FileReadyToParse")
+	if err != nil {
+		return err
+	}
+	if diagnostics, ok := DecodeDiagnostics(blob); ok {
+		SetDiagnostics(p.Name(), diagnostics)
+	}
+	return nil
+}
+
+func (p *PythonIde) HasDiagnosticsOnPut() bool {
+	settings := GetIdeSettings()
+	filetypes := p.Filetypes()
+	if settings == nil || len(filetypes) == 0 {
+		return false
+	}
+	return settings.DiagnosticsOnPut[filetypes[0]]
+}
+
+// The error count last reported in +Errors after a Put, by path.
+var errorCountsAfterPut = map[string]int{}
+var errorCountsAfterPutLock sync.Mutex
+
+// Record the file's error count after a Put. True if there are errors and the count isn't the one reported last time,
+// so +Errors only comes up when something changed.
+func ShouldReportErrorsAfterPut(path string, errorCount int) bool {
+	errorCountsAfterPutLock.Lock()
+	defer errorCountsAfterPutLock.Unlock()
+	previous := errorCountsAfterPut[path]
+	errorCountsAfterPut[path] = errorCount
+	return errorCount > 0 && errorCount != previous
+}
+
+// The summary and errors written to +Errors after a Put. Warnings are only counted.
+func FormatErrorsAfterPut(path string, diagnostics []Diagnostic) string {
+	errorCount, warningCount := CountDiagnostics(diagnostics)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s, %s\n", path, pluralise(errorCount, "error"), pluralise(warningCount, "warning"))
+	for i := range diagnostics {
+		if diagnostics[i].IsError() {
+			fmt.Fprintf(&b, "%s\n", diagnostics[i].String())
+		}
+	}
+	return b.String()
+}
+
+// How long after a Put diagnostics for the file still count as its result. Completers that push diagnostics
+// through receive_messages send them some time after FileReadyToParse returns.
+const diagnosticsAfterPutWindow = 30 * time.Second
+
+// When each file was last Put, while its diagnostics are awaited.
+var putsAwaitingDiagnostics = map[string]time.Time{}
+var putsAwaitingDiagnosticsLock sync.Mutex
+
+func ExpectDiagnosticsAfterPut(path string) {
+	putsAwaitingDiagnosticsLock.Lock()
+	defer putsAwaitingDiagnosticsLock.Unlock()
+	putsAwaitingDiagnostics[path] = time.Now()
+}
+
+// True for the first diagnostics for path to arrive soon enough after a Put.
+func TakeDiagnosticsAfterPut(path string) bool {
+	putsAwaitingDiagnosticsLock.Lock()
+	defer putsAwaitingDiagnosticsLock.Unlock()
+	put, ok := putsAwaitingDiagnostics[path]
+	delete(putsAwaitingDiagnostics, path)
+	return ok && time.Since(put) < diagnosticsAfterPutWindow
+}
+
+// Report the errors in the first diagnostics to arrive after a Put, however they came: in the FileReadyToParse reply
+// or pushed through receive_messages.
+func ReportErrorsAfterPut(path string, diagnostics []Diagnostic) {
+	if !TakeDiagnosticsAfterPut(path) {
+		return
+	}
+	errorCount, _ := CountDiagnostics(diagnostics)
+	if !ShouldReportErrorsAfterPut(path, errorCount) {
+		return
+	}
+	ids := BufferIdsFor(path)
+	if len(ids) == 0 {
+		return
+	}
+	err := AcmeWriteToErrors(ids[0], path, FormatErrorsAfterPut(path, diagnostics))
+	if err != nil {
+		log.Printf("Error reporting diagnostics for %s: %s\n", path, err)
+	}
+}
+
+// Tell ycmd the file was saved and reparse it. The errors are reported when the diagnostics come in. Completers that
+// push diagnostics reply to FileReadyToParse with the ones they had before the save, so for them only the pushed ones
+// are waited for.
+func (p *PythonIde) CheckDiagnosticsAfterPut(ctx context.Context) error {
+	pushed := false
+	if filetypes := p.Filetypes(); len(filetypes) > 0 {
+		pushed = PushesMessages(filetypes[0])
+	}
+	if !pushed {
+		ExpectDiagnosticsAfterPut(p.Name())
+	}
+	_, err := p.SendEventNotification(ctx, "FileSave")
+	if err != nil {
+		return err
+	}
+	err = p.RefreshDiagnostics(ctx)
+	if err != nil {
+		return err
+	}
+	if pushed {
+		ExpectDiagnosticsAfterPut(p.Name())
+	}
+	return nil
+}
+
+// Diag parses the window and opens its +Diag window.
+func (p *PythonIde) ShowDiagnostics(ctx context.Context) error {
+	err := p.RefreshDiagnostics(ctx)
+	if err != nil {
+		return err
+	}
+	return AcmeReplaceWindowBody(DiagnosticsWindowName(p.Name()), FormatDiagnostics(p.Name(), DiagnosticsFor(p.Name())))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+)
+
+const someDiagnostics = `[
+  {"kind": "WARNING", "text": "unused variable 'y'", "location": {"line_num": 7, "column_num": 9, "filepath": "/src/a.cpp"},
+   "location_extent": {"start": {"line_num": 7, "column_num": 9, "filepath": "/src/a.cpp"}, "end": {"line_num": 7, "column_num": 10, "filepath": "/src/a.cpp"}},
+   "ranges": [], "fixit_available": false},
+  {"kind": "ERROR", "text": "use of undeclared identifier 'x'", "location": {"line_num": 3, "column_num": 5, "filepath": "/src/a.cpp"},
+   "location_extent": {"start": {"line_num": 3, "column_num": 5, "filepath": "/src/a.cpp"}, "end": {"line_num": 3, "column_num": 6, "filepath": "/src/a.cpp"}},
+   "ranges": [], "fixit_available": true}
+]`
+
+func TestDecodeDiagnostics(t *testing.T) {
+	diagnostics, ok := DecodeDiagnostics([]byte(someDiagnostics))
+	if !ok || len(diagnostics) != 2 {
+		t.Logf("%+v", diagnostics)
+		t.FailNow()
+	}
+	if !diagnostics[1].IsError() || !diagnostics[1].FixItAvailable || diagnostics[1].LocationExtent.End.ColumnNum != 6 {
+		t.Logf("%+v", diagnostics[1])
+		t.Fail()
+	}
+	if _, ok := DecodeDiagnostics([]byte(`{}`)); ok {
+		t.Log("An empty reply isn't a list of diagnostics")
+		t.Fail()
+	}
+}
+
+func TestFormatDiagnostics(t *testing.T) {
+	diagnostics, _ := DecodeDiagnostics([]byte(someDiagnostics))
+	SortDiagnostics(diagnostics)
+	expected := "Diagnostics for /src/a.cpp: 1 error, 1 warning\n\n" +
+		"/src/a.cpp:3:5\terror: use of undeclared identifier 'x'\n" +
+		"/src/a.cpp:7:9\twarning: unused variable 'y'\n"
+	if actual := FormatDiagnostics("/src/a.cpp", diagnostics); actual != expected {
+		t.Log(actual)
+		t.Fail()
+	}
+}
+
+func TestSetDiagnostics(t *testing.T) {
+	var notified []Diagnostic
+	handlers := DiagnosticsHandlers
+	DiagnosticsHandlers = []func(path string, diagnostics []Diagnostic){
+		func(path string, diagnostics []Diagnostic) { notified = diagnostics },
+	}
+	defer func() { DiagnosticsHandlers = handlers }()
+
+	diagnostics, _ := DecodeDiagnostics([]byte(someDiagnostics))
+	SetDiagnostics("/src/a.cpp", diagnostics)
+	stored := DiagnosticsFor("/src/a.cpp")
+	if len(stored) != 2 || stored[0].Location.LineNum != 3 || len(notified) != 2 {
+		t.Logf("Diagnostics should be stored sorted and handlers told: %+v", stored)
+		t.Fail()
+	}
+	SetDiagnostics("/src/a.cpp", nil)
+	if DiagnosticsFor("/src/a.cpp") != nil || len(notified) != 0 {
+		t.Log("No diagnostics should clear the file")
+		t.Fail()
+	}
+}
+
+func TestShouldReportErrorsAfterPut(t *testing.T) {
+	path := "/src/put.cpp"
+	for _, c := range []struct {
+		errors   int
+		expected bool
+	}{
+		{0, false},
+		{2, true},
+		{2, false},
+		{1, true},
+		{0, false},
+		{1, true},
+	} {
+		if actual := ShouldReportErrorsAfterPut(path, c.errors); actual != c.expected {
+			t.Logf("%d errors: got %t", c.errors, actual)
+			t.Fail()
+		}
+	}
+}
+
+func TestFormatErrorsAfterPut(t *testing.T) {
+	diagnostics, _ := DecodeDiagnostics([]byte(someDiagnostics))
+	SortDiagnostics(diagnostics)
+	expected := "/src/a.cpp: 1 error, 1 warning\n" +
+		"/src/a.cpp:3:5\terror: use of undeclared identifier 'x'\n"
+	if actual := FormatErrorsAfterPut("/src/a.cpp", diagnostics); actual != expected {
+		t.Log(actual)
+		t.Fail()
+	}
+}
+
+func TestTakeDiagnosticsAfterPut(t *testing.T) {
+	path := "/src/pushed.cpp"
+	if TakeDiagnosticsAfterPut(path) {
+		t.Log("Nothing was Put")
+		t.Fail()
+	}
+	ExpectDiagnosticsAfterPut(path)
+	if !TakeDiagnosticsAfterPut(path) {
+		t.Log("The first diagnostics after a Put are its result")
+		t.Fail()
+	}
+	if TakeDiagnosticsAfterPut(path) {
+		t.Log("Later ones aren't")
+		t.Fail()
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"9fans.net/go/acme"
+)
+
+// What to do with a .ycm_extra_conf.py ycmd asks about.
+type ExtraConfDecision int
+
+const (
+	// Ask the user in +Errors.
+	ExtraConfUndecided ExtraConfDecision = iota
+	ExtraConfLoad      ExtraConfDecision = iota
+	ExtraConfIgnore    ExtraConfDecision = iota
+)
+
+// The extra conf files the user has answered Load or Ignore for, kept in ConfigDir so they're only asked once.
+// Entries are globs, like extra_conf_globlist.
+type ExtraConfDecisions struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+var extraConfDecisions *ExtraConfDecisions
+var extraConfDecisionsLock sync.Mutex
+
+// A job that failed waiting for an answer about an extra conf file, to be queued again on its window once it's loaded.
+type pendingExtraConfRetry struct {
+	ide Ide
+	win *acme.Win
+	job *IdeJob
+}
+
+var pendingExtraConfRetries = map[string][]pendingExtraConfRetry{}
+var pendingExtraConfRetriesLock sync.Mutex
+
+func ExtraConfDecisionsPath() string {
+	configDir := ConfigDir()
+	if configDir == "" {
+		return ""
+	}
+	return filepath.Join(configDir, "extra_conf.json")
+}
+
+func NewExtraConfDecisionsFromFile(path string) (*ExtraConfDecisions, error) {
+	decisions := new(ExtraConfDecisions)
+	blob, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return decisions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(blob, decisions)
+	if err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+func (d *ExtraConfDecisions) WriteFile(path string) error {
+	blob, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, blob, 0644)
+}
+
+func (d *ExtraConfDecisions) Decide(path string) ExtraConfDecision {
+	// Deny wins, so a broad allow glob can't override a file the user said no to.
+	if matchesAnyGlob(d.Deny, path) {
+		return ExtraConfIgnore
+	}
+	if matchesAnyGlob(d.Allow, path) {
+		return ExtraConfLoad
+	}
+	return ExtraConfUndecided
+}
+
+// Record a decision, replacing any earlier one for the same path.
+func (d *ExtraConfDecisions) Remember(path string, decision ExtraConfDecision) {
+	d.Allow = removeString(d.Allow, path)
+	d.Deny = removeString(d.Deny, path)
+	switch decision {
+	case ExtraConfLoad:
+		d.Allow = append(d.Allow, path)
+	case ExtraConfIgnore:
+		d.Deny = append(d.Deny, path)
+	}
+}
+
+func removeString(list []string, s string) []string {
+	kept := list[:0]
+	for _, item := range list {
+		if item != s {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+func expandHome(pattern string) string {
+	if pattern == "~" || strings.HasPrefix(pattern, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, pattern[1:])
+		}
+	}
+	return pattern
+}
+
+// The regexp for a glob, translated the way Python's fnmatch does it, which is what ycmd matches globlists with. Unlike
+// filepath.Match, * and ? match / too, so /src/* covers every file below /src.
+func fnmatchRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^(?s:")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			j := i + 1
+			if j < len(runes) && runes[j] == '!' {
+				j++
+			}
+			if j < len(runes) && runes[j] == ']' {
+				j++
+			}
+			for j < len(runes) && runes[j] != ']' {
+				j++
+			}
+			if j >= len(runes) {
+				b.WriteString(`\[`)
+				continue
+			}
+			set := string(runes[i+1 : j])
+			set = strings.ReplaceAll(set, `\`, `\\`)
+			set = strings.ReplaceAll(set, "[", `\[`)
+			if strings.HasPrefix(set, "!") {
+				set = "^" + set[1:]
+			} else if strings.HasPrefix(set, "^") {
+				set = `\` + set
+			}
+			fmt.Fprintf(&b, "[%s]", set)
+			i = j
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(")$")
+	return regexp.Compile(b.String())
+}
+
+func fnmatch(pattern, path string) bool {
+	re, err := fnmatchRegexp(expandHome(pattern))
+	if err != nil {
+		log.Printf("Bad glob %q: %s\n", pattern, err)
+		return false
+	}
+	return re.MatchString(path)
+}
+
+func matchesAnyGlob(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if fnmatch(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check path against extra_conf_globlist the way ycmd does: the first matching pattern wins, and a pattern starting
+// with "!" ignores the file.
+func ExtraConfGloblistDecision(globlist []string, path string) ExtraConfDecision {
+	for _, pattern := range globlist {
+		blacklist := strings.HasPrefix(pattern, "!")
+		if !fnmatch(strings.TrimPrefix(pattern, "!"), path) {
+			continue
+		}
+		if blacklist {
+			return ExtraConfIgnore
+		}
+		return ExtraConfLoad
+	}
+	return ExtraConfUndecided
+}
+
+func GetExtraConfGloblist() []string {
+	currentSettingsLock.Lock()
+	defer currentSettingsLock.Unlock()
+	if currentSettings == nil {
+		return nil
+	}
+	return currentSettings.ExtraConfGloblist
+}
+
+func getExtraConfDecisions() *ExtraConfDecisions {
+	if extraConfDecisions != nil {
+		return extraConfDecisions
+	}
+	extraConfDecisions = new(ExtraConfDecisions)
+	if path := ExtraConfDecisionsPath(); path != "" {
+		decisions, err := NewExtraConfDecisionsFromFile(path)
+		if err != nil {
+			log.Printf("Error reading %s: %s\n", path, err)
+		} else {
+			extraConfDecisions = decisions
+		}
+	}
+	return extraConfDecisions
+}
+
+// What to do with path without asking: extra_conf_globlist first, then what the user answered before.
+func ExtraConfDecisionFor(path string) ExtraConfDecision {
+	if decision := ExtraConfGloblistDecision(GetExtraConfGloblist(), path); decision != ExtraConfUndecided {
+		return decision
+	}
+	extraConfDecisionsLock.Lock()
+	defer extraConfDecisionsLock.Unlock()
+	return getExtraConfDecisions().Decide(path)
+}
+
+func RememberExtraConfDecision(path string, decision ExtraConfDecision) error {
+	extraConfDecisionsLock.Lock()
+	defer extraConfDecisionsLock.Unlock()
+	decisions := getExtraConfDecisions()
+	decisions.Remember(path, decision)
+	decisionsPath := ExtraConfDecisionsPath()
+	if decisionsPath == "" {
+		return nil
+	}
+	return decisions.WriteFile(decisionsPath)
+}
+
+func extraConfHandler(decision ExtraConfDecision) string {
+	if decision == ExtraConfLoad {
+		return "load_extra_conf_file"
+	}
+	return "ignore_extra_conf_file"
+}
+
+// Tell ycmd to load or ignore the extra conf file.
+func AnswerExtraConf(ctx context.Context, path string, decision ExtraConfDecision) error {
+	_, err := PostHandler(ctx, extraConfHandler(decision), &YcmdRequest{Filepath: path})
+	return err
+}
+
+func FormatExtraConfPrompt(path string) string {
+	return fmt.Sprintf("%s runs Python code from the project. Click Load or Ignore to decide:\n"+
+		"Load %s\n"+
+		"Ignore %s\n", path, path, path)
+}
+
+// Run a window's job. If ycmd won't go on without a decision about a .ycm_extra_conf.py, make it from
+// extra_conf_globlist or an earlier answer if there is one, otherwise ask in +Errors and queue the job on the window
+// again once the file is loaded.
+func RunWithExtraConf(ctx context.Context, ide Ide, win *acme.Win, job *IdeJob) error {
+	err := job.Run(ctx)
+	ycmdError, ok := err.(*YcmdError)
+	if !ok || ycmdError.Type() != "UnknownExtraConf" {
+		return err
+	}
+	path := ycmdError.ExceptionString("extra_conf_file")
+	if path == "" {
+		return err
+	}
+	switch ExtraConfDecisionFor(path) {
+	case ExtraConfLoad:
+		answerErr := AnswerExtraConf(ctx, path, ExtraConfLoad)
+		if answerErr != nil {
+			return answerErr
+		}
+		return job.Run(ctx)
+	case ExtraConfIgnore:
+		answerErr := AnswerExtraConf(ctx, path, ExtraConfIgnore)
+		if answerErr != nil {
+			return answerErr
+		}
+		return errors.New(fmt.Sprintf("%s is ignored. Remove it from %s to be asked again.", path, ExtraConfDecisionsPath()))
+	}
+	pendingExtraConfRetriesLock.Lock()
+	pendingExtraConfRetries[path] = append(pendingExtraConfRetries[path], pendingExtraConfRetry{ide: ide, win: win, job: job})
+	pendingExtraConfRetriesLock.Unlock()
+	return AcmeWriteToErrors(ide.Id(), ide.Name(), FormatExtraConfPrompt(path))
+}
+
+// Remember the user's answer, pass it on to ycmd, and after Load queue whatever was waiting on it on the windows it
+// came from, so it runs in order with their other jobs.
+func DecideExtraConf(ctx context.Context, path string, decision ExtraConfDecision) error {
+	err := RememberExtraConfDecision(path, decision)
+	if err != nil {
+		log.Printf("Error remembering decision for %s: %s\n", path, err)
+	}
+	err = AnswerExtraConf(ctx, path, decision)
+	if err != nil {
+		return err
+	}
+	pendingExtraConfRetriesLock.Lock()
+	retries := pendingExtraConfRetries[path]
+	delete(pendingExtraConfRetries, path)
+	pendingExtraConfRetriesLock.Unlock()
+	if decision != ExtraConfLoad {
+		return nil
+	}
+	for _, retry := range retries {
+		QueueIdeJob(retry.ide, retry.win, retry.job)
+	}
+	return nil
+}
+
+var extraConfCommands = map[string]ExtraConfDecision{
+	"Load":   ExtraConfLoad,
+	"Ignore": ExtraConfIgnore,
+}
+
+func isExtraConfPending(path string) bool {
+	pendingExtraConfRetriesLock.Lock()
+	defer pendingExtraConfRetriesLock.Unlock()
+	_, ok := pendingExtraConfRetries[path]
+	return ok
+}
+
+// The path and answer a click gives: button 2 on Load or Ignore in a prompt line in +Errors. The path comes from the
+// rest of the line, so clicking the word is enough. Only paths ycmd is waiting on are answered, so a stray click
+// elsewhere can't allow a file. winName and lineAt, which returns the line at a body offset, are only asked for once
+// the click looks like an answer.
+func ExtraConfClickAnswer(e *acme.Event, winName func() string, lineAt func(q int) string) (string, ExtraConfDecision, bool) {
+	area, err := WhichAcmeArea(e)
+	if err != nil || area != AcmeAreaBody {
+		return "", ExtraConfUndecided, false
+	}
+	button, err := WhichAcmeButton(e)
+	if err != nil || button != AcmeButtonTwo {
+		return "", ExtraConfUndecided, false
+	}
+	fields := strings.Fields(string(e.Text))
+	if len(fields) == 0 {
+		return "", ExtraConfUndecided, false
+	}
+	decision, ok := extraConfCommands[fields[0]]
+	if !ok || !strings.HasSuffix(winName(), "/+Errors") {
+		return "", ExtraConfUndecided, false
+	}
+	path := strings.Join(fields[1:], " ")
+	if path == "" {
+		path = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lineAt(e.OrigQ0)), fields[0]))
+	}
+	if !isExtraConfPending(path) {
+		return "", ExtraConfUndecided, false
+	}
+	return path, decision, true
+}
+
+// Answer a prompt in +Errors. Load is also an acme builtin, so the click has to be caught here, before acme runs it.
+// Returns nil if the event should be passed back to acme.
+func HandleExtraConfClick(ide Ide, win *acme.Win, e *acme.Event) (*IdeJob, error) {
+	winName := func() string {
+		name, _ := AcmeWinName(win)
+		return name
+	}
+	lineAt := func(q int) string {
+		body, err := win.ReadAll("body")
+		if err != nil {
+			return ""
+		}
+		line, _ := LineAndColumnOfOffset([]rune(string(body)), q)
+		lines := strings.Split(string(body), "\n")
+		if line > len(lines) {
+			return ""
+		}
+		return lines[line-1]
+	}
+	path, decision, ok := ExtraConfClickAnswer(e, winName, lineAt)
+	if !ok {
+		return nil, nil
+	}
+	return &IdeJob{Name: strings.Fields(string(e.Text))[0], Run: func(ctx context.Context) error {
+		return DecideExtraConf(ctx, path, decision)
+	}}, nil
+}
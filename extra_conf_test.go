@@ -0,0 +1,151 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"9fans.net/go/acme"
+)
+
+func TestExtraConfGloblistDecision(t *testing.T) {
+	globlist := []string{"!/src/untrusted/*", "/src/*/.ycm_extra_conf.py"}
+	cases := []struct {
+		Path     string
+		Expected ExtraConfDecision
+	}{
+		{"/src/project/.ycm_extra_conf.py", ExtraConfLoad},
+		{"/src/untrusted/.ycm_extra_conf.py", ExtraConfIgnore},
+		{"/elsewhere/.ycm_extra_conf.py", ExtraConfUndecided},
+		// Like ycmd's fnmatch, * goes into subdirectories.
+		{"/src/untrusted/deep/.ycm_extra_conf.py", ExtraConfIgnore},
+		{"/src/project/sub/.ycm_extra_conf.py", ExtraConfLoad},
+	}
+	for _, c := range cases {
+		if decision := ExtraConfGloblistDecision(globlist, c.Path); decision != c.Expected {
+			t.Logf("%s: expected %d, got %d", c.Path, c.Expected, decision)
+			t.Fail()
+		}
+	}
+}
+
+func TestExtraConfDecisions_Remember(t *testing.T) {
+	decisions := &ExtraConfDecisions{Allow: []string{"/src/*/.ycm_extra_conf.py"}}
+	path := "/src/project/.ycm_extra_conf.py"
+	if decisions.Decide(path) != ExtraConfLoad {
+		t.Log("Allow globs should match")
+		t.FailNow()
+	}
+	decisions.Remember(path, ExtraConfIgnore)
+	if decisions.Decide(path) != ExtraConfIgnore {
+		t.Log("Deny should win over a broader allow")
+		t.FailNow()
+	}
+	decisions.Remember(path, ExtraConfLoad)
+	if len(decisions.Deny) != 0 || len(decisions.Allow) != 2 {
+		t.Logf("Changing a decision should replace it: %+v", decisions)
+		t.FailNow()
+	}
+}
+
+func TestExtraConfDecisions_WriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acmeide")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lib", "acmeide", "extra_conf.json")
+	decisions, err := NewExtraConfDecisionsFromFile(path)
+	if err != nil {
+		t.Log("A missing file should mean no decisions yet")
+		t.FailNow()
+	}
+	decisions.Remember("/src/project/.ycm_extra_conf.py", ExtraConfIgnore)
+	err = decisions.WriteFile(path)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	read, err := NewExtraConfDecisionsFromFile(path)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if read.Decide("/src/project/.ycm_extra_conf.py") != ExtraConfIgnore {
+		t.Logf("Decision didn't survive a round trip: %+v", read)
+		t.Fail()
+	}
+}
+
+func TestFormatExtraConfPrompt(t *testing.T) {
+	expected := "/src/.ycm_extra_conf.py runs Python code from the project. Click Load or Ignore to decide:\n" +
+		"Load /src/.ycm_extra_conf.py\n" +
+		"Ignore /src/.ycm_extra_conf.py\n"
+	if prompt := FormatExtraConfPrompt("/src/.ycm_extra_conf.py"); prompt != expected {
+		t.Log(prompt)
+		t.Fail()
+	}
+}
+
+func TestExtraConfClickAnswer(t *testing.T) {
+	path := "/src/project/.ycm_extra_conf.py"
+	pendingExtraConfRetriesLock.Lock()
+	pendingExtraConfRetries[path] = nil
+	pendingExtraConfRetriesLock.Unlock()
+	defer func() {
+		pendingExtraConfRetriesLock.Lock()
+		delete(pendingExtraConfRetries, path)
+		pendingExtraConfRetriesLock.Unlock()
+	}()
+	errorsWindow := func() string { return "/src/project/+Errors" }
+	promptLine := func(q int) string { return "Load " + path }
+	// Load is an acme builtin, so the click comes flagged as one.
+	e := &acme.Event{C1: 'M', C2: 'X', Flag: 1, Text: []byte("Load")}
+	answered, decision, ok := ExtraConfClickAnswer(e, errorsWindow, promptLine)
+	if !ok || answered != path || decision != ExtraConfLoad {
+		t.Logf("%s %d %t", answered, decision, ok)
+		t.Fail()
+	}
+	ignore := &acme.Event{C1: 'M', C2: 'X', Text: []byte("Ignore " + path)}
+	if _, decision, ok := ExtraConfClickAnswer(ignore, errorsWindow, promptLine); !ok || decision != ExtraConfIgnore {
+		t.Fail()
+	}
+	otherWindow := func() string { return "/src/project/notes.txt" }
+	if _, _, ok := ExtraConfClickAnswer(e, otherWindow, promptLine); ok {
+		t.Log("Only clicks in +Errors answer prompts")
+		t.Fail()
+	}
+	notPending := func(q int) string { return "Load /elsewhere/.ycm_extra_conf.py" }
+	if _, _, ok := ExtraConfClickAnswer(e, errorsWindow, notPending); ok {
+		t.Log("Only files ycmd is waiting on can be answered")
+		t.Fail()
+	}
+}
+
+func TestFnmatch(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	cases := []struct {
+		Pattern  string
+		Path     string
+		Expected bool
+	}{
+		{"~/src/*", filepath.Join(home, "src/proj/.ycm_extra_conf.py"), true},
+		{"/src/proj?/*", "/src/proj1/.ycm_extra_conf.py", true},
+		{"/src/[!x]*/*", "/src/xproj/.ycm_extra_conf.py", false},
+		{"/src/[a-c]*", "/src/bproj/.ycm_extra_conf.py", true},
+		{"/src/a.b/*", "/src/axb/.ycm_extra_conf.py", false},
+		{"/src/[proj", "/src/[proj", true},
+	}
+	for _, c := range cases {
+		if actual := fnmatch(c.Pattern, c.Path); actual != c.Expected {
+			t.Logf("%s against %s: expected %t", c.Pattern, c.Path, c.Expected)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"9fans.net/go/acme"
+)
+
+type FixItRange struct {
+	Start FileLocation `json:"start"`
+	End   FileLocation `json:"end"`
+}
+
+type FixItChunk struct {
+	ReplacementText string     `json:"replacement_text"`
+	Range           FixItRange `json:"range"`
+}
+
+type FixIt struct {
+	Text     string       `json:"text"`
+	Kind     string       `json:"kind"`
+	Chunks   []FixItChunk `json:"chunks"`
+	Location FileLocation `json:"location"`
+	// Set when the chunks have to be asked for with /resolve_fixit before applying.
+	Resolve bool `json:"resolve"`
+	// The FixIt as ycmd sent it, which is what /resolve_fixit wants back.
+	Raw json.RawMessage `json:"-"`
+}
+
+type FixItResponse struct {
+	FixIts []json.RawMessage `json:"fixits"`
+}
+
+func DecodeFixIts(blob []byte) ([]FixIt, error) {
+	response := FixItResponse{}
+	err := json.Unmarshal(blob, &response)
+	if err != nil {
+		return nil, err
+	}
+	fixIts := make([]FixIt, len(response.FixIts))
+	for i, raw := range response.FixIts {
+		err = json.Unmarshal(raw, &fixIts[i])
+		if err != nil {
+			return nil, err
+		}
+		fixIts[i].Raw = raw
+	}
+	return fixIts, nil
+}
+
+// Byte offset of a 1-based line and byte column, clamped to the text.
+func byteOffsetOf(lines []string, lineNum, columnNum int) int {
+	offset := 0
+	for i := 0; i < lineNum-1 && i < len(lines); i++ {
+		offset += len(lines[i]) + 1
+	}
+	if lineNum-1 < len(lines) {
+		column := columnNum - 1
+		if column > len(lines[lineNum-1]) {
+			column = len(lines[lineNum-1])
+		}
+		if column > 0 {
+			offset += column
+		}
+	}
+	return offset
+}
+
+// An edit of text, in byte offsets.
+type TextEdit struct {
+	Start       int
+	End         int
+	Replacement string
+}
+
+// Shrink an edit to the part that actually changes, so formatters that send back the whole file only touch the lines
+// they changed.
+func MinimizeEdit(text string, edit TextEdit) TextEdit {
+	old := text[edit.Start:edit.End]
+	replacement := edit.Replacement
+	prefix := 0
+	for prefix < len(old) && prefix < len(replacement) && old[prefix] == replacement[prefix] {
+		prefix++
+	}
+	// Don't split a rune.
+	for prefix > 0 && prefix < len(old) && !utf8.RuneStart(old[prefix]) {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(replacement)-prefix &&
+		old[len(old)-1-suffix] == replacement[len(replacement)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && !utf8.RuneStart(old[len(old)-suffix]) {
+		suffix--
+	}
+	return TextEdit{
+		Start:       edit.Start + prefix,
+		End:         edit.End - suffix,
+		Replacement: replacement[prefix : len(replacement)-suffix],
+	}
+}
+
+// The chunks for one file as minimal edits, last first so applying them in order keeps the offsets valid.
+func EditsForChunks(text string, chunks []FixItChunk) []TextEdit {
+	lines := strings.Split(text, "\n")
+	edits := make([]TextEdit, 0, len(chunks))
+	for _, chunk := range chunks {
+		start := byteOffsetOf(lines, chunk.Range.Start.LineNum, chunk.Range.Start.ColumnNum)
+		end := byteOffsetOf(lines, chunk.Range.End.LineNum, chunk.Range.End.ColumnNum)
+		if end < start {
+			start, end = end, start
+		}
+		edit := MinimizeEdit(text, TextEdit{Start: start, End: end, Replacement: chunk.ReplacementText})
+		if edit.Start == edit.End && edit.Replacement == "" {
+			continue
+		}
+		edits = append(edits, edit)
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Start > edits[j].Start
+	})
+	return edits
+}
+
+func ApplyEditsToText(text string, edits []TextEdit) string {
+	for _, edit := range edits {
+		text = text[:edit.Start] + edit.Replacement + text[edit.End:]
+	}
+	return text
+}
+
+// Apply edits one at a time through the window's addr and data files, so acme keeps dot and can undo them.
+func ApplyEditsToWindow(win *acme.Win, text string, edits []TextEdit) error {
+	for _, edit := range edits {
+		q0 := utf8.RuneCountInString(text[:edit.Start])
+		q1 := q0 + utf8.RuneCountInString(text[edit.Start:edit.End])
+		err := win.Addr("#%d,#%d", q0, q1)
+		if err != nil {
+			return err
+		}
+		_, err = win.Write("data", []byte(edit.Replacement))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func acmeWindowFor(path string) (*acme.Win, error) {
+	windows, err := acme.Windows()
+	if err != nil {
+		return nil, err
+	}
+	for _, window := range windows {
+		if window.Name == path {
+			return acme.Open(window.ID, nil)
+		}
+	}
+	return nil, nil
+}
+
+// Open path in a new acme window, so edits to it can be looked over and undone before it's Put.
+func acmeOpenFile(path string) (*acme.Win, error) {
+	win, err := acme.New()
+	if err != nil {
+		return nil, err
+	}
+	err = win.Name("%s", path)
+	if err == nil {
+		err = win.Ctl("get")
+	}
+	if err != nil {
+		win.CloseFiles()
+		return nil, err
+	}
+	return win, nil
+}
+
+// One FixIt with the chunks of all of them. Their offsets all refer to the text before any is applied, so they have to
+// go in together, last first, rather than one FixIt after another.
+func MergeFixIts(fixIts []FixIt) *FixIt {
+	merged := &FixIt{}
+	for i := range fixIts {
+		merged.Chunks = append(merged.Chunks, fixIts[i].Chunks...)
+	}
+	return merged
+}
+
+// Apply a FixIt to the windows of the files it touches. Files that aren't open are opened first, and nothing is
+// written to disk until the user Puts.
+func ApplyFixIt(fixIt *FixIt) error {
+	chunksByFile := map[string][]FixItChunk{}
+	var paths []string
+	for _, chunk := range fixIt.Chunks {
+		path := chunk.Range.Start.Filepath
+		if _, ok := chunksByFile[path]; !ok {
+			paths = append(paths, path)
+		}
+		chunksByFile[path] = append(chunksByFile[path], chunk)
+	}
+	for _, path := range paths {
+		win, err := acmeWindowFor(path)
+		if err != nil {
+			return err
+		}
+		if win == nil {
+			win, err = acmeOpenFile(path)
+			if err != nil {
+				return err
+			}
+		}
+		body, err := win.ReadAll("body")
+		if err != nil {
+			win.CloseFiles()
+			return err
+		}
+		err = ApplyEditsToWindow(win, string(body), EditsForChunks(string(body), chunksByFile[path]))
+		win.CloseFiles()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
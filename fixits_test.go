@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const FormatFixItJson = `{"fixits": [{"text": "", "chunks": [
+	{"replacement_text": "import os\nimport sys\n\n\ndef f(a, b):\n    return a\n",
+	 "range": {"start": {"filepath": "/tmp/wtf.py", "line_num": 1, "column_num": 1},
+	           "end": {"filepath": "/tmp/wtf.py", "line_num": 6, "column_num": 1}}}
+]}]}`
+
+func TestEditsForChunks(t *testing.T) {
+	fixIts, err := DecodeFixIts([]byte(FormatFixItJson))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	text := "import os\nimport sys\n\ndef f(a,b):\n    return a\n"
+	edits := EditsForChunks(text, fixIts[0].Chunks)
+	if len(edits) != 1 {
+		t.Logf("Expected 1 edit but received %+v\n", edits)
+		t.FailNow()
+	}
+	// The whole file came back, but only the blank line and the missing space change.
+	if edits[0].Start != 22 || edits[0].End != 30 || edits[0].Replacement != "\ndef f(a, " {
+		t.Logf("Edit wasn't minimal: %+v\n", edits[0])
+		t.Fail()
+	}
+	expected := "import os\nimport sys\n\n\ndef f(a, b):\n    return a\n"
+	if actual := ApplyEditsToText(text, edits); actual != expected {
+		t.Logf("Expected:\n%s\nbut received:\n%s\n", expected, actual)
+		t.Fail()
+	}
+}
+
+func TestMinimizeEditKeepsRunesWhole(t *testing.T) {
+	text := "x = «a»"
+	edit := MinimizeEdit(text, TextEdit{Start: 0, End: len(text), Replacement: "x = «b»"})
+	if text[:edit.Start]+edit.Replacement+text[edit.End:] != "x = «b»" {
+		t.Logf("Edit doesn't apply: %+v\n", edit)
+		t.Fail()
+	}
+	if !json.Valid([]byte(`"` + edit.Replacement + `"`)) {
+		t.Logf("Edit split a rune: %q\n", edit.Replacement)
+		t.Fail()
+	}
+}
+
+func TestMergeFixIts(t *testing.T) {
+	chunk := func(line int, replacement string) FixItChunk {
+		return FixItChunk{ReplacementText: replacement, Range: FixItRange{
+			Start: FileLocation{Filepath: "/tmp/a.py", LineNum: line, ColumnNum: 1},
+			End:   FileLocation{Filepath: "/tmp/a.py", LineNum: line, ColumnNum: 1}}}
+	}
+	// Both insert at the start of a line of the original text.
+	fixIts := []FixIt{{Chunks: []FixItChunk{chunk(1, "import os\n")}}, {Chunks: []FixItChunk{chunk(2, "# b\n")}}}
+	text := "a = 1\nb = 2\n"
+	merged := MergeFixIts(fixIts)
+	expected := "import os\na = 1\n# b\nb = 2\n"
+	if actual := ApplyEditsToText(text, EditsForChunks(text, merged.Chunks)); actual != expected {
+		t.Logf("Expected:\n%s\nbut received:\n%s\n", expected, actual)
+		t.Fail()
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Tag commands that run a ycmd subcommand returning FixIts, and apply them.
+var FixItCommands = map[string]string{
+	"Fmt":     "Format",
+	"Imports": "OrganizeImports",
+}
+
+// Run Format, OrganizeImports or a similar subcommand and apply every FixIt it returns.
+func (p *PythonIde) RunFixItCommand(ctx context.Context, subcommand string) error {
+	ycmdRequest, err := p.NewYcmdRequest(subcommand)
+	if err != nil {
+		return err
+	}
+	if subcommand == "Format" {
+		// ycmd insists on formatting options. Acme has no notion of them, so use the common defaults.
+		ycmdRequest.ExtraFields = map[string]interface{}{
+			"options": map[string]interface{}{"tab_size": 4, "insert_spaces": true},
+		}
+	}
+	blob, err := PostHandler(ctx, "run_completer_command", ycmdRequest)
+	if err != nil {
+		return err
+	}
+	fixIts, err := DecodeFixIts(blob)
+	if err != nil {
+		return err
+	}
+	if len(fixIts) == 0 {
+		return errors.New(fmt.Sprintf("%s: nothing to do for %s", subcommand, p.Name()))
+	}
+	return ApplyFixIt(MergeFixIts(fixIts))
+}
+
+func (p *PythonIde) formatOnPutSubcommands() []string {
+	settings := GetIdeSettings()
+	filetypes := p.Filetypes()
+	if settings == nil || len(filetypes) == 0 {
+		return nil
+	}
+	return settings.FormatOnPut[filetypes[0]]
+}
+
+func (p *PythonIde) HasFormatOnPut() bool {
+	return len(p.formatOnPutSubcommands()) > 0
+}
+
+// Run the format_on_put subcommands for the window's filetype. Called before Put is passed back to acme, so what gets
+// written is the formatted text. Failures are logged and don't stop the Put.
+func (p *PythonIde) FormatBeforePut(ctx context.Context) {
+	for _, subcommand := range p.formatOnPutSubcommands() {
+		err := p.RunFixItCommand(ctx, subcommand)
+		if err != nil {
+			log.Printf("%s before Put of %s: %s\n", subcommand, p.Name(), err)
+		}
+	}
+}
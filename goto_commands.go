@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Arguments to Goto, swept along with it in the tag or chorded, and the ycmd subcommand each one runs.
+var GoToArguments = map[string]string{
+	"def":     "GoToDefinition",
+	"decl":    "GoToDeclaration",
+	"impl":    "GoToImplementation",
+	"type":    "GoToType",
+	"include": "GoToInclude",
+	"refs":    "GoToReferences",
+	"sym":     "GoToSymbol",
+}
+
+// True for the ycmd GoTo subcommands, which can also be typed into the tag and executed directly.
+func IsGoToSubcommand(command string) bool {
+	if command == "GoTo" {
+		return true
+	}
+	for _, subcommand := range GoToArguments {
+		if command == subcommand {
+			return true
+		}
+	}
+	return false
+}
+
+// Work out which ycmd subcommand a command runs, and its extra arguments. A bare Goto is GoTo on button 3 and
+// GoToReferences on button 2.
+func GoToSubcommandFor(i *IdeCommand) (string, []string, error) {
+	if i.Command != "Goto" {
+		return i.Command, i.Args, nil
+	}
+	if len(i.Args) == 0 {
+		if i.Button == AcmeButtonTwo {
+			return "GoToReferences", nil, nil
+		}
+		return "GoTo", nil, nil
+	}
+	if subcommand, ok := GoToArguments[i.Args[0]]; ok {
+		return subcommand, i.Args[1:], nil
+	}
+	if IsGoToSubcommand(i.Args[0]) {
+		return i.Args[0], i.Args[1:], nil
+	}
+	return "", nil, errors.New(fmt.Sprintf("unknown Goto argument: %s", i.Args[0]))
+}
+
+// Results of GoToReferences get their own window, everything else shares +Goto.
+func GoToResultsKind(subcommand string) string {
+	if subcommand == "GoToReferences" {
+		return "References"
+	}
+	return "Goto"
+}
+
+func (p *PythonIde) RunGoToCommand(ctx context.Context, i *IdeCommand, subcommand string, args []string) error {
+	ycmdRequest, err := p.NewYcmdRequest(subcommand)
+	if err != nil {
+		return err
+	}
+	if subcommand == "GoToSymbol" && len(args) == 0 {
+		// No query was swept or chorded, so use the selection in the body.
+		query, err := AcmeDotText(p.acmeWin, ycmdRequest.FileContents)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(query) == "" {
+			return errors.New("GoToSymbol needs a query: Goto sym <query>, or select one")
+		}
+		args = []string{strings.TrimSpace(query)}
+	}
+	ycmdRequest.CommandArguments = append(ycmdRequest.CommandArguments, args...)
+	blob, err := PostHandler(ctx, "run_completer_command", ycmdRequest)
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("%s from %s:%d:%d", strings.Join(ycmdRequest.CommandArguments, " "), p.Name(),
+		ycmdRequest.LineNum, ycmdRequest.ColumnNum)
+	return p.HandleGoToResponse(i, GoToResultsKind(subcommand), title, blob)
+}
+
+// ycmd answers every GoTo subcommand with either a single location, which we jump to, or a list of them, which goes
+// to a results window.
+func (p *PythonIde) HandleGoToResponse(i *IdeCommand, kind, title string, blob []byte) error {
+	var (
+		fileLocation  = FileLocation{}
+		fileLocations = FileLocations{}
+	)
+	err := json.Unmarshal(blob, &fileLocation)
+	if err == nil && fileLocation.Filepath != "" {
+		return AcmeJumpTo(p, p.acmeWin, &fileLocation, CurrentNavigationPolicy(i.Command, i.Button), true)
+	}
+	err = json.Unmarshal(blob, &fileLocations)
+	if err != nil {
+		return err
+	}
+	log.Printf("Received Multi Response: %+v\n", fileLocations)
+	if len(fileLocations) == 0 {
+		return errors.New(fmt.Sprintf("%s: no results", title))
+	}
+	if len(fileLocations) == 1 {
+		return AcmeJumpTo(p, p.acmeWin, &fileLocations[0], CurrentNavigationPolicy(i.Command, i.Button), true)
+	}
+	return ShowResults(p.Name(), kind, title, fileLocations)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGoToSubcommandFor(t *testing.T) {
+	cases := []struct {
+		command    *IdeCommand
+		subcommand string
+		args       string
+	}{
+		{&IdeCommand{Command: "Goto", Button: AcmeButtonThree}, "GoTo", ""},
+		{&IdeCommand{Command: "Goto", Button: AcmeButtonTwo}, "GoToReferences", ""},
+		{&IdeCommand{Command: "Goto", Args: []string{"impl"}, Button: AcmeButtonTwo}, "GoToImplementation", ""},
+		{&IdeCommand{Command: "Goto", Args: []string{"sym", "IgorStyle"}, Button: AcmeButtonTwo}, "GoToSymbol", "IgorStyle"},
+		{&IdeCommand{Command: "GoToType", Button: AcmeButtonTwo}, "GoToType", ""},
+	}
+	for _, c := range cases {
+		subcommand, args, err := GoToSubcommandFor(c.command)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if subcommand != c.subcommand || strings.Join(args, " ") != c.args {
+			t.Logf("%+v: expected %s %q but received %s %q\n", c.command, c.subcommand, c.args, subcommand, args)
+			t.Fail()
+		}
+	}
+	_, _, err := GoToSubcommandFor(&IdeCommand{Command: "Goto", Args: []string{"nowhere"}})
+	if err == nil {
+		t.Log("Expected an error for an unknown Goto argument")
+		t.Fail()
+	}
+}
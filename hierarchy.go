@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// How far up the supertypes are walked when a hierarchy is shown. Subtypes are only shown one level down; click on
+// one to see its own subtypes.
+const hierarchySupertypeDepth = 5
+
+type TypeHierarchyProvider interface {
+	// The node for the type at location.
+	Root(ctx context.Context, location FileLocation) (*TreeNode, error)
+	// The direct supertypes or subtypes of a node.
+	Resolve(ctx context.Context, node *TreeNode, direction string) ([]*TreeNode, error)
+}
+
+// An item from ycmd's TypeHierarchy subcommand or /resolve_type_hierarchy. Raw is handed back to ycmd to resolve it.
+type HierarchyItem struct {
+	Kind      string          `json:"kind"`
+	Name      string          `json:"name"`
+	Locations FileLocations   `json:"locations"`
+	Raw       json.RawMessage `json:"-"`
+}
+
+func DecodeHierarchyItems(blob []byte) ([]HierarchyItem, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(blob, &raws); err != nil {
+		raws = []json.RawMessage{blob}
+	}
+	items := make([]HierarchyItem, 0, len(raws))
+	for _, raw := range raws {
+		item := HierarchyItem{}
+		err := json.Unmarshal(raw, &item)
+		if err != nil {
+			return nil, err
+		}
+		if len(item.Locations) == 0 {
+			continue
+		}
+		item.Raw = raw
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Type hierarchies from completers that have them, e.g. clangd.
+type completerTypeHierarchy struct{}
+
+func hierarchyItemNode(item HierarchyItem) *TreeNode {
+	return &TreeNode{
+		Location: item.Locations[0],
+		Label:    strings.TrimSpace(fmt.Sprintf("%s %s", strings.ToLower(item.Kind), item.Name)),
+		Data:     &item,
+	}
+}
+
+func (h *completerTypeHierarchy) Root(ctx context.Context, location FileLocation) (*TreeNode, error) {
+	ycmdRequest, err := NewLocationYcmdRequest(location, "TypeHierarchy")
+	if err != nil {
+		return nil, err
+	}
+	blob, err := PostHandler(ctx, "run_completer_command", ycmdRequest)
+	if err != nil {
+		return nil, err
+	}
+	items, err := DecodeHierarchyItems(blob)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New(fmt.Sprintf("no type at %s", location.String()))
+	}
+	return hierarchyItemNode(items[0]), nil
+}
+
+func (h *completerTypeHierarchy) Resolve(ctx context.Context, node *TreeNode, direction string) ([]*TreeNode, error) {
+	item, ok := node.Data.(*HierarchyItem)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%s wasn't returned by ycmd", node.Location.String()))
+	}
+	ycmdRequest, err := NewLocationYcmdRequest(node.Location)
+	if err != nil {
+		return nil, err
+	}
+	ycmdRequest.ExtraFields = map[string]interface{}{"resolve": item.Raw, "direction": direction}
+	blob, err := PostHandler(ctx, "resolve_type_hierarchy", ycmdRequest)
+	if err != nil {
+		return nil, err
+	}
+	items, err := DecodeHierarchyItems(blob)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*TreeNode, 0, len(items))
+	for _, item := range items {
+		nodes = append(nodes, hierarchyItemNode(item))
+	}
+	return nodes, nil
+}
+
+// Jedi has no type hierarchy, so walk it with GoTo: on base classes for supertypes, and GoToReferences filtered to
+// class statements for subtypes. Only single line class statements are understood.
+type pythonTypeHierarchy struct{}
+
+var pythonClassRegexp = regexp.MustCompile(`^\s*class\s+(\w+)\s*(\()?`)
+
+// The 1-based column of the class name, and of each base class, in a class statement.
+func ParsePythonClass(line string) (int, []int, bool) {
+	match := pythonClassRegexp.FindStringSubmatchIndex(line)
+	if match == nil {
+		return 0, nil, false
+	}
+	nameColumn := match[2] + 1
+	if match[4] < 0 {
+		return nameColumn, nil, true
+	}
+	var baseColumns []int
+	depth := 0
+	start := match[5]
+	for i := start; i <= len(line); i++ {
+		var c byte = ')'
+		if i < len(line) {
+			c = line[i]
+		}
+		if c == '(' || c == '[' {
+			depth++
+			continue
+		}
+		if depth > 0 && (c == ')' || c == ']') && i < len(line) {
+			depth--
+			continue
+		}
+		if depth == 0 && (c == ',' || c == ')') {
+			part := line[start:i]
+			trimmed := strings.TrimSpace(part)
+			if trimmed != "" && !strings.Contains(trimmed, "=") && !strings.HasPrefix(trimmed, "*") {
+				offset := start + strings.Index(part, trimmed)
+				if dot := strings.LastIndex(trimmed, "."); dot >= 0 {
+					offset += dot + 1
+				}
+				baseColumns = append(baseColumns, offset+1)
+			}
+			if c == ')' {
+				break
+			}
+			start = i + 1
+		}
+	}
+	return nameColumn, baseColumns, true
+}
+
+func sourceLine(path string, lineNum int) (string, error) {
+	lines, err := SourceLines(path)
+	if err != nil {
+		return "", err
+	}
+	if lineNum < 1 || lineNum > len(lines) {
+		return "", errors.New(fmt.Sprintf("%s has no line %d", path, lineNum))
+	}
+	return lines[lineNum-1], nil
+}
+
+// The node for the class statement at location, if there is one.
+func pythonClassNode(location FileLocation) (*TreeNode, bool) {
+	line, err := sourceLine(location.Filepath, location.LineNum)
+	if err != nil {
+		return nil, false
+	}
+	nameColumn, _, ok := ParsePythonClass(line)
+	if !ok {
+		return nil, false
+	}
+	location.ColumnNum = nameColumn
+	return &TreeNode{Location: location, Label: strings.TrimSpace(line)}, true
+}
+
+func pythonGoTo(ctx context.Context, location FileLocation, subcommand string) (FileLocations, error) {
+	ycmdRequest, err := NewLocationYcmdRequest(location, subcommand)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := PostHandler(ctx, "run_completer_command", ycmdRequest)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeFileLocations(blob)
+}
+
+func (h *pythonTypeHierarchy) Root(ctx context.Context, location FileLocation) (*TreeNode, error) {
+	if node, ok := pythonClassNode(location); ok {
+		return node, nil
+	}
+	// Dot is on a use of the class rather than its definition.
+	locations, err := pythonGoTo(ctx, location, "GoTo")
+	if err != nil {
+		return nil, err
+	}
+	for _, definition := range locations {
+		if node, ok := pythonClassNode(definition); ok {
+			return node, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("no class at %s", location.String()))
+}
+
+func (h *pythonTypeHierarchy) Resolve(ctx context.Context, node *TreeNode, direction string) ([]*TreeNode, error) {
+	line, err := sourceLine(node.Location.Filepath, node.Location.LineNum)
+	if err != nil {
+		return nil, err
+	}
+	nameColumn, baseColumns, ok := ParsePythonClass(line)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no class at %s", node.Location.String()))
+	}
+	var nodes []*TreeNode
+	if direction == "supertypes" {
+		for _, baseColumn := range baseColumns {
+			base := node.Location
+			base.ColumnNum = baseColumn
+			locations, err := pythonGoTo(ctx, base, "GoTo")
+			if err != nil {
+				log.Printf("Hier: GoTo base class at %s: %s\n", base.String(), err)
+				continue
+			}
+			for _, location := range locations {
+				if baseNode, ok := pythonClassNode(location); ok {
+					nodes = append(nodes, baseNode)
+					break
+				}
+			}
+		}
+		return nodes, nil
+	}
+	name := node.Location
+	name.ColumnNum = nameColumn
+	references, err := pythonGoTo(ctx, name, "GoToReferences")
+	if err != nil {
+		return nil, err
+	}
+	for _, reference := range references {
+		referenceLine, err := sourceLine(reference.Filepath, reference.LineNum)
+		if err != nil {
+			continue
+		}
+		subclassNameColumn, subclassBaseColumns, ok := ParsePythonClass(referenceLine)
+		if !ok || reference.Filepath == node.Location.Filepath && reference.LineNum == node.Location.LineNum {
+			continue
+		}
+		// The reference has to be among the bases, after the subclass name, not the subclass name itself.
+		if len(subclassBaseColumns) > 0 && reference.ColumnNum >= subclassBaseColumns[0] {
+			subclass := reference
+			subclass.ColumnNum = subclassNameColumn
+			nodes = append(nodes, &TreeNode{Location: subclass, Label: strings.TrimSpace(referenceLine)})
+		}
+	}
+	return nodes, nil
+}
+
+// Fill in node's supertypes or subtypes, depth levels deep, labelled with the direction they go.
+func resolveTypeHierarchy(ctx context.Context, provider TypeHierarchyProvider, node *TreeNode, direction string, depth int) error {
+	children, err := provider.Resolve(ctx, node, direction)
+	if err != nil {
+		return err
+	}
+	arrow := "↑"
+	if direction == "subtypes" {
+		arrow = "↓"
+	}
+	for _, child := range children {
+		child.Label = fmt.Sprintf("%s %s", arrow, child.Label)
+		if depth > 1 {
+			err := resolveTypeHierarchy(ctx, provider, child, direction, depth-1)
+			if err != nil {
+				log.Printf("Hier: %s of %s: %s\n", direction, child.Location.String(), err)
+			}
+		}
+	}
+	node.Children = append(node.Children, children...)
+	node.Expanded = true
+	return nil
+}
+
+// Show root with its supertypes and subtypes in the named window. Button 2 on any type re-roots the tree there.
+func ShowTypeHierarchy(ctx context.Context, windowName string, provider TypeHierarchyProvider, root *TreeNode) error {
+	root.Children = nil
+	root.Label = strings.TrimLeft(root.Label, "↑↓ ")
+	err := resolveTypeHierarchy(ctx, provider, root, "supertypes", hierarchySupertypeDepth)
+	if err != nil {
+		return err
+	}
+	err = resolveTypeHierarchy(ctx, provider, root, "subtypes", 1)
+	if err != nil {
+		return err
+	}
+	view := &TreeView{
+		Name:  windowName,
+		Title: fmt.Sprintf("Type hierarchy of %s", root.Location.String()),
+		Root:  root,
+		Activate: func(ctx context.Context, view *TreeView, node *TreeNode) error {
+			return ShowTypeHierarchy(ctx, view.Name, provider, node)
+		},
+	}
+	return ShowTree(view, 0)
+}
+
+func (p *PythonIde) ShowTypeHierarchy(ctx context.Context) error {
+	ycmdRequest, err := p.NewYcmdRequest()
+	if err != nil {
+		return err
+	}
+	location := FileLocation{LineNum: ycmdRequest.LineNum, ColumnNum: ycmdRequest.ColumnNum, Filepath: p.Name()}
+	var provider TypeHierarchyProvider = &completerTypeHierarchy{}
+	root, err := provider.Root(ctx, location)
+	if err != nil {
+		if len(ycmdRequest.Filetypes) == 0 || ycmdRequest.Filetypes[0] != "python" {
+			return err
+		}
+		log.Printf("TypeHierarchy failed, walking GoTo instead: %s\n", err)
+		provider = &pythonTypeHierarchy{}
+		root, err = provider.Root(ctx, location)
+		if err != nil {
+			return err
+		}
+	}
+	return ShowTypeHierarchy(ctx, ResultsWindowName(p.Name(), "Hier"), provider, root)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePythonClass(t *testing.T) {
+	cases := []struct {
+		line        string
+		nameColumn  int
+		baseColumns []int
+		ok          bool
+	}{
+		{"class IgorStyle(Style):", 7, []int{17}, true},
+		{"    class Inner(pygments.style.Style, Generic[T], metaclass=Meta):", 11, []int{32, 39}, true},
+		{"class Plain:", 7, nil, true},
+		{"def render(self):", 0, nil, false},
+	}
+	for _, c := range cases {
+		nameColumn, baseColumns, ok := ParsePythonClass(c.line)
+		if ok != c.ok || nameColumn != c.nameColumn || !reflect.DeepEqual(baseColumns, c.baseColumns) {
+			t.Logf("%q: expected %d %v %t but received %d %v %t\n", c.line, c.nameColumn, c.baseColumns, c.ok,
+				nameColumn, baseColumns, ok)
+			t.Fail()
+		}
+	}
+}
+
+func TestDecodeHierarchyItems(t *testing.T) {
+	blob := []byte(`[{"kind": "Class", "name": "Style", "locations": [{"filepath": "/tmp/style.py", "line_num": 4,
+		"column_num": 7}], "handle": 12}, {"kind": "Class", "name": "Nowhere", "locations": []}]`)
+	items, err := DecodeHierarchyItems(blob)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if len(items) != 1 || items[0].Name != "Style" || items[0].Locations[0].LineNum != 4 {
+		t.Logf("Unexpected items: %+v\n", items)
+		t.FailNow()
+	}
+	node := hierarchyItemNode(items[0])
+	if node.Label != "class Style" {
+		t.Logf("Expected label %q but received %q\n", "class Style", node.Label)
+		t.Fail()
+	}
+}
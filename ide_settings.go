@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// IdeSettings are the settings for acmeide itself, as opposed to YcmdSettings which are handed to ycmd.
+type IdeSettings struct {
+	NavigationPolicy          string            `json:"navigation_policy"`
+	NavigationPolicyOverrides map[string]string `json:"navigation_policy_overrides"`
+	// The filetype to ask ycmd about from windows that aren't source files, when the project doesn't say.
+	DefaultFiletype string `json:"default_filetype"`
+	// Show signature help in +Doc as "(" and "," are typed.
+	SignatureHelpAuto bool `json:"signature_help_auto"`
+	// How many lines either side of dot Hints asks for.
+	InlayHintsContextLines int `json:"inlay_hints_context_lines"`
+	// Subcommands such as Format and OrganizeImports to run before Put, by filetype.
+	FormatOnPut map[string][]string `json:"format_on_put"`
+	// Filetypes whose windows are reparsed for diagnostics after every Put.
+	DiagnosticsOnPut map[string]bool `json:"diagnostics_on_put"`
+	// Tag file names looked for from each file's directory up, or absolute paths, sent to ycmd as tag_files.
+	TagFiles []string `json:"tag_files"`
+	// How often the in-memory copy of a window body is checked against acme.
+	ShadowResyncSeconds int `json:"shadow_resync_seconds"`
+	// Seconds to wait for ycmd, by handler or "handler:Subcommand", with "default" for the rest.
+	RequestTimeouts map[string]float64 `json:"request_timeouts"`
+}
+
+func NewIdeSettingsFromFile(path string) (*IdeSettings, error) {
+	ideSettings := new(IdeSettings)
+	err := ideSettings.MergeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ideSettings, nil
+}
+
+// Overlay the settings in path on top of the current settings. Keys missing from the file keep their current value.
+func (s *IdeSettings) MergeFile(path string) error {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(blob, s)
+}
+
+// Like MergeFile, but a missing file is not an error.
+func (s *IdeSettings) MergeFileIfExists(path string) error {
+	err := s.MergeFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
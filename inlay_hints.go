@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"9fans.net/go/acme"
+)
+
+type InlayHint struct {
+	Kind         string       `json:"kind"`
+	Position     FileLocation `json:"position"`
+	Label        string       `json:"label"`
+	PaddingLeft  bool         `json:"paddingLeft"`
+	PaddingRight bool         `json:"paddingRight"`
+}
+
+// Text inserted into a rendered line, in runes, so columns can be mapped back to the source.
+type HintSpan struct {
+	Start  int
+	Length int
+}
+
+// A rendered +Hints window: which file it shows, and where hints were inserted on each line.
+type InlayHintsView struct {
+	Path  string
+	Spans [][]HintSpan
+}
+
+var inlayHintsViews = map[string]*InlayHintsView{}
+var inlayHintsViewsLock sync.Mutex
+
+func InlayHintsWindowName(path string) string {
+	return path + "+Hints"
+}
+
+func formatInlayHint(hint InlayHint) string {
+	label := "‹" + strings.TrimSpace(hint.Label) + "›"
+	if hint.PaddingLeft {
+		label = " " + label
+	}
+	if hint.PaddingRight {
+		label = label + " "
+	}
+	return label
+}
+
+// Interleave hints with the source, one rendered line per source line so the two stay aligned. Hint columns are ycmd's
+// 1-based byte columns.
+func RenderInlayHints(lines []string, hints []InlayHint) (string, [][]HintSpan) {
+	hintsByLine := map[int][]InlayHint{}
+	for _, hint := range hints {
+		hintsByLine[hint.Position.LineNum] = append(hintsByLine[hint.Position.LineNum], hint)
+	}
+	spans := make([][]HintSpan, len(lines))
+	var b strings.Builder
+	for i, line := range lines {
+		lineHints := hintsByLine[i+1]
+		sort.SliceStable(lineHints, func(a, c int) bool {
+			return lineHints[a].Position.ColumnNum < lineHints[c].Position.ColumnNum
+		})
+		written := 0
+		renderedRunes := 0
+		for _, hint := range lineHints {
+			at := hint.Position.ColumnNum - 1
+			if at < written {
+				at = written
+			}
+			if at > len(line) {
+				at = len(line)
+			}
+			b.WriteString(line[written:at])
+			renderedRunes += utf8.RuneCountInString(line[written:at])
+			written = at
+			label := formatInlayHint(hint)
+			b.WriteString(label)
+			spans[i] = append(spans[i], HintSpan{Start: renderedRunes, Length: utf8.RuneCountInString(label)})
+			renderedRunes += utf8.RuneCountInString(label)
+		}
+		b.WriteString(line[written:])
+		if i < len(lines)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), spans
+}
+
+// Map a 1-based rune column in a rendered line back to the source line. Columns inside a hint map to where the hint
+// was inserted.
+func SourceColumn(spans []HintSpan, renderedColumn int) int {
+	offset := renderedColumn - 1
+	removed := 0
+	for _, span := range spans {
+		if offset < span.Start {
+			break
+		}
+		if offset < span.Start+span.Length {
+			return span.Start - removed + 1
+		}
+		removed += span.Length
+	}
+	return offset - removed + 1
+}
+
+// The 1-based line and rune column of rune offset q in body.
+func LineAndColumnOfOffset(body []rune, q int) (int, int) {
+	line, column := 1, 1
+	for i := 0; i < q && i < len(body); i++ {
+		if body[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
+
+// Acme doesn't say which lines are visible, so hints are requested for the lines around dot.
+func (p *PythonIde) ShowInlayHints(ctx context.Context) error {
+	ycmdRequest, err := p.NewYcmdRequest()
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(ycmdRequest.FileContents, "\n")
+	contextLines := 100
+	if settings := GetIdeSettings(); settings != nil && settings.InlayHintsContextLines > 0 {
+		contextLines = settings.InlayHintsContextLines
+	}
+	startLine := ycmdRequest.LineNum - contextLines
+	if startLine < 1 {
+		startLine = 1
+	}
+	endLine := ycmdRequest.LineNum + contextLines
+	if endLine > len(lines) {
+		endLine = len(lines)
+	}
+	ycmdRequest.Range = &YcmdRange{
+		Start: YcmdPosition{LineNum: startLine, ColumnNum: 1},
+		End:   YcmdPosition{LineNum: endLine, ColumnNum: len(lines[endLine-1]) + 1},
+	}
+	blob, err := PostHandler(ctx, "inlay_hints", ycmdRequest)
+	if err != nil {
+		return err
+	}
+	var hints []InlayHint
+	err = json.Unmarshal(blob, &hints)
+	if err != nil {
+		return err
+	}
+	rendered, spans := RenderInlayHints(lines, hints)
+	windowName := InlayHintsWindowName(p.Name())
+	inlayHintsViewsLock.Lock()
+	inlayHintsViews[windowName] = &InlayHintsView{Path: p.Name(), Spans: spans}
+	inlayHintsViewsLock.Unlock()
+	return AcmeReplaceWindowBodyAt(windowName, rendered, fmt.Sprintf("%d", ycmdRequest.LineNum))
+}
+
+// Button 3 anywhere in a +Hints window jumps to the same place in the source, using the "Hints" navigation policy.
+// Returns false if the event should be passed back to acme.
+func HandleHintsClick(ide Ide, win *acme.Win, e *acme.Event) (*IdeJob, error) {
+	area, err := WhichAcmeArea(e)
+	if err != nil || area != AcmeAreaBody {
+		return nil, nil
+	}
+	button, err := WhichAcmeButton(e)
+	if err != nil || button != AcmeButtonThree {
+		return nil, nil
+	}
+	winName, err := AcmeWinName(win)
+	if err != nil {
+		return nil, nil
+	}
+	inlayHintsViewsLock.Lock()
+	view, ok := inlayHintsViews[winName]
+	inlayHintsViewsLock.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	if _, err := os.Stat(view.Path); err != nil {
+		return nil, nil
+	}
+	body, err := win.ReadAll("body")
+	if err != nil {
+		return nil, err
+	}
+	line, column := LineAndColumnOfOffset([]rune(string(body)), e.OrigQ0)
+	if line-1 < len(view.Spans) {
+		column = SourceColumn(view.Spans[line-1], column)
+	}
+	location := &FileLocation{LineNum: line, ColumnNum: column, Filepath: view.Path}
+	policy := CurrentNavigationPolicy("Hints", button)
+	if policy == NavigateAuto {
+		// Opening in place would replace the hints we just clicked in.
+		policy = NavigateReuseWindow
+	}
+	return NewJumpJob(ide, win, location, policy), nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRenderInlayHints(t *testing.T) {
+	lines := []string{"x = f(1, 2)", "y = x"}
+	hints := []InlayHint{
+		{Kind: "Parameter", Position: FileLocation{LineNum: 1, ColumnNum: 10}, Label: "b:", PaddingRight: true},
+		{Kind: "Parameter", Position: FileLocation{LineNum: 1, ColumnNum: 7}, Label: "a:", PaddingRight: true},
+		{Kind: "Type", Position: FileLocation{LineNum: 2, ColumnNum: 2}, Label: ": int"},
+	}
+	rendered, spans := RenderInlayHints(lines, hints)
+	expected := "x = f(‹a:› 1, ‹b:› 2)\ny‹: int› = x"
+	if rendered != expected {
+		t.Logf("Expected:\n%s\nbut received:\n%s\n", expected, rendered)
+		t.FailNow()
+	}
+	// The "2" after the second hint is column 10 in the source and column 18 once rendered.
+	if actual := SourceColumn(spans[0], 18); actual != 10 {
+		t.Logf("Expected source column 10 but received %d\n", actual)
+		t.Fail()
+	}
+	// Clicking inside a hint lands where it was inserted.
+	if actual := SourceColumn(spans[1], 4); actual != 2 {
+		t.Logf("Expected source column 2 but received %d\n", actual)
+		t.Fail()
+	}
+}
+
+func TestLineAndColumnOfOffset(t *testing.T) {
+	line, column := LineAndColumnOfOffset([]rune("ab\n‹c›d\n"), 5)
+	if line != 2 || column != 3 {
+		t.Logf("Expected 2:3 but received %d:%d\n", line, column)
+		t.Fail()
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"9fans.net/go/acme"
+)
+
+// NavigationPolicy decides where a jump to a Location is opened.
+type NavigationPolicy int
+
+const (
+	// Open in place if the window is clean and the destination isn't open elsewhere, otherwise plumb.
+	NavigateAuto NavigationPolicy = iota
+	// Always open the destination in a brand new window, even if the file is already open.
+	NavigateNewWindow NavigationPolicy = iota
+	// Reuse the window that already has the file open, or open a new one. This is what the plumber does.
+	NavigateReuseWindow NavigationPolicy = iota
+	// Open the destination in the preview window of the column jumped from, replacing whatever it showed before.
+	NavigatePreviewWindow NavigationPolicy = iota
+)
+
+var navigationPolicyNames = map[string]NavigationPolicy{
+	"auto":    NavigateAuto,
+	"new":     NavigateNewWindow,
+	"reuse":   NavigateReuseWindow,
+	"preview": NavigatePreviewWindow,
+}
+
+func ParseNavigationPolicy(name string) (NavigationPolicy, error) {
+	policy, ok := navigationPolicyNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return NavigateAuto, errors.New(fmt.Sprintf("unknown navigation policy: %q", name))
+	}
+	return policy, nil
+}
+
+// The key used in navigation_policy_overrides, e.g. "Goto:3" for button 3 on Goto.
+func NavigationPolicyKey(command string, button AcmeButton) string {
+	return fmt.Sprintf("%s:%s", command, button)
+}
+
+// Look up the policy for a command and button. A "Goto:3" override wins over a "Goto" override, which wins over the
+// global navigation_policy.
+func NavigationPolicyFor(settings *IdeSettings, command string, button AcmeButton) NavigationPolicy {
+	if settings == nil {
+		return NavigateAuto
+	}
+	name := settings.NavigationPolicy
+	if override, ok := settings.NavigationPolicyOverrides[command]; ok {
+		name = override
+	}
+	if override, ok := settings.NavigationPolicyOverrides[NavigationPolicyKey(command, button)]; ok {
+		name = override
+	}
+	if name == "" {
+		return NavigateAuto
+	}
+	policy, err := ParseNavigationPolicy(name)
+	if err != nil {
+		log.Printf("NavigationPolicyFor: %s: %s\n", NavigationPolicyKey(command, button), err)
+	}
+	return policy
+}
+
+func CurrentNavigationPolicy(command string, button AcmeButton) NavigationPolicy {
+	return NavigationPolicyFor(GetIdeSettings(), command, button)
+}
+
+func AcmePlumb(location Location) error {
+	cmd := exec.Command("plumb", location.String())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Println("plumb " + location.String() + ": " + string(output))
+		return err
+	}
+	return nil
+}
+
+// Replace the whole body of win with the file at location, name the window after it, and select the address.
+func AcmeLoadLocation(win *acme.Win, location Location) error {
+	err := win.Addr("0,$")
+	if err != nil {
+		return err
+	}
+	log.Println("AcmeLoadLocation: Set addr")
+	fileContents, err := ioutil.ReadFile(location.Path())
+	if err != nil {
+		return err
+	}
+	log.Println("AcmeLoadLocation: Read file " + location.Path())
+	_, err = win.Write("data", fileContents)
+	if err != nil {
+		return err
+	}
+	log.Println("AcmeLoadLocation: wrote data")
+	err = win.Name(location.Path())
+	if err != nil {
+		return err
+	}
+	log.Println("AcmeLoadLocation: wrote name")
+	err = win.Addr(location.Addr())
+	if err != nil {
+		log.Printf("AcmeLoadLocation: error writing addr: %s\n", location.Addr())
+		return err
+	}
+	err = win.Ctl("dot=addr")
+	if err != nil {
+		log.Printf("AcmeLoadLocation: error writing ctl: dot=addr\n")
+	}
+	err = win.Ctl("clean")
+	if err != nil {
+		log.Printf("AcmeLoadLocation: error writing ctl: clean\n")
+	}
+	err = win.Ctl("show")
+	if err != nil {
+		log.Printf("AcmeLoadLocation: error writing ctl: show\n")
+	}
+	return nil
+}
+
+func AcmeOpenInNewWindow(location Location) error {
+	win, err := acme.New()
+	if err != nil {
+		return err
+	}
+	defer win.CloseFiles()
+	return AcmeLoadLocation(win, location)
+}
+
+// The preview windows we've opened. Acme doesn't say which column a window is in, but every window in a column is as
+// wide as the column, which ctl does say, so a jump reuses the preview as wide as the window it came from. Columns
+// that happen to be exactly as wide share a preview. A new preview goes where acme puts new windows, the column the
+// user is working in.
+var previewWindows = map[int]struct{}{}
+var previewWindowsLock sync.Mutex
+
+// Stop reusing the window as a preview, e.g. because it was closed.
+func ForgetPreviewWindow(id int) {
+	previewWindowsLock.Lock()
+	defer previewWindowsLock.Unlock()
+	delete(previewWindows, id)
+}
+
+func acmeWinCtl(id int) (*AcmeCtl, error) {
+	win, err := acme.Open(id, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer win.CloseFiles()
+	return readAcmeCtl(win)
+}
+
+// The previews as wide as width that can be reused, because the user hasn't edited them, in the order they were
+// opened.
+func reusablePreviews(previews []int, width int, ctlOf func(id int) (*AcmeCtl, error)) []int {
+	var reusable []int
+	for _, id := range previews {
+		ctl, err := ctlOf(id)
+		if err != nil {
+			// Closed without acme telling us.
+			ForgetPreviewWindow(id)
+			continue
+		}
+		if ctl.Width == width && !ctl.IsDirty {
+			reusable = append(reusable, id)
+		}
+	}
+	return reusable
+}
+
+func previewWindowFor(sourceId int) (*acme.Win, error) {
+	source, err := acmeWinCtl(sourceId)
+	if err != nil {
+		return nil, err
+	}
+	previewWindowsLock.Lock()
+	previews := make([]int, 0, len(previewWindows))
+	for id := range previewWindows {
+		previews = append(previews, id)
+	}
+	previewWindowsLock.Unlock()
+	sort.Ints(previews)
+	for _, id := range reusablePreviews(previews, source.Width, acmeWinCtl) {
+		if win, err := acme.Open(id, nil); err == nil {
+			return win, nil
+		}
+	}
+	win, err := acme.New()
+	if err != nil {
+		return nil, err
+	}
+	id, err := acmeWinId(win)
+	if err != nil {
+		win.CloseFiles()
+		return nil, err
+	}
+	previewWindowsLock.Lock()
+	previewWindows[id] = struct{}{}
+	previewWindowsLock.Unlock()
+	return win, nil
+}
+
+func AcmeOpenInPreviewWindow(sourceId int, location Location) error {
+	win, err := previewWindowFor(sourceId)
+	if err != nil {
+		return err
+	}
+	defer win.CloseFiles()
+	return AcmeLoadLocation(win, location)
+}
+
+// Windows that list locations, one per line, for the user to click on.
+var resultsWindowSuffixes = []string{"/+Errors", "/+Goto", "/+References", "/+Symbols", "+Outline", "+Diag", "/+Problems"}
+
+func IsResultsWindow(winName string) bool {
+	for _, suffix := range resultsWindowSuffixes {
+		if strings.HasSuffix(winName, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Button 3 on a location in a results window jumps there using the "Results" navigation policy, rather than leaving it
+// to the plumber, and records the jump in history. Returns false if the event wasn't a result click and should be
+// passed back to acme.
+func HandleResultsClick(ide Ide, win *acme.Win, e *acme.Event) (*IdeJob, error) {
+	area, err := WhichAcmeArea(e)
+	if err != nil || area != AcmeAreaBody {
+		return nil, nil
+	}
+	button, err := WhichAcmeButton(e)
+	if err != nil || button != AcmeButtonThree {
+		return nil, nil
+	}
+	// Results windows we create ourselves have no name yet when acme tells us about them, so ask the tag.
+	winName, err := AcmeWinName(win)
+	if err != nil || !IsResultsWindow(winName) {
+		return nil, nil
+	}
+	location, err := ParseLocation(string(e.Text), filepath.Dir(winName))
+	if err != nil {
+		return nil, nil
+	}
+	if _, err := os.Stat(location.Path()); err != nil {
+		return nil, nil
+	}
+	policy := CurrentNavigationPolicy("Results", button)
+	if policy == NavigateAuto {
+		// Opening in place would replace the results list we just clicked in.
+		policy = NavigateReuseWindow
+	}
+	return NewJumpJob(ide, win, location, policy), nil
+}
+
+// A jump from a click, queued like any other job so it waits for the window's earlier ones.
+func NewJumpJob(ide Ide, win *acme.Win, location Location, policy NavigationPolicy) *IdeJob {
+	return &IdeJob{Name: "Jump", Run: func(ctx context.Context) error {
+		return AcmeJumpTo(ide, win, location, policy, true)
+	}}
+}
+
+// The current name of the window, which is the first word of its tag.
+func AcmeWinName(win *acme.Win) (string, error) {
+	tag, err := win.ReadAll("tag")
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(tag))
+	if len(fields) < 1 {
+		return "", nil
+	}
+	return fields[0], nil
+}
+
+func acmeWinId(win *acme.Win) (int, error) {
+	ctlBytes, err := win.ReadAll("ctl")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(ctlBytes))
+	if len(fields) < 1 {
+		return 0, errors.New(fmt.Sprintf("Invalid ctl: %s", string(ctlBytes)))
+	}
+	return strconv.Atoi(fields[0])
+}
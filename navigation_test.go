@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseNavigationPolicy(t *testing.T) {
+	policy, err := ParseNavigationPolicy(" Preview ")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if policy != NavigatePreviewWindow {
+		t.Logf("Expected policy %d but received %d\n", NavigatePreviewWindow, policy)
+		t.Fail()
+	}
+	_, err = ParseNavigationPolicy("sideways")
+	if err == nil {
+		t.Log("Expected an error for an unknown policy")
+		t.Fail()
+	}
+}
+
+func TestNavigationPolicyFor(t *testing.T) {
+	settings := &IdeSettings{
+		NavigationPolicy: "reuse",
+		NavigationPolicyOverrides: map[string]string{
+			"Goto":   "preview",
+			"Goto:2": "new",
+		},
+	}
+	cases := []struct {
+		command  string
+		button   AcmeButton
+		expected NavigationPolicy
+	}{
+		{"Goto", AcmeButtonTwo, NavigateNewWindow},
+		{"Goto", AcmeButtonThree, NavigatePreviewWindow},
+		{"Nav", AcmeButtonThree, NavigateReuseWindow},
+	}
+	for _, c := range cases {
+		actual := NavigationPolicyFor(settings, c.command, c.button)
+		if actual != c.expected {
+			t.Logf("%s: expected policy %d but received %d\n", NavigationPolicyKey(c.command, c.button), c.expected, actual)
+			t.Fail()
+		}
+	}
+}
+
+func TestReusablePreviews(t *testing.T) {
+	ctls := map[int]*AcmeCtl{
+		11: {Id: 11, Width: 640},
+		12: {Id: 12, Width: 800},
+		13: {Id: 13, Width: 640, IsDirty: true},
+		15: {Id: 15, Width: 640},
+	}
+	ctlOf := func(id int) (*AcmeCtl, error) {
+		ctl, ok := ctls[id]
+		if !ok {
+			return nil, errors.New("no such window")
+		}
+		return ctl, nil
+	}
+	previewWindowsLock.Lock()
+	previewWindows[14] = struct{}{}
+	previewWindowsLock.Unlock()
+	actual := reusablePreviews([]int{11, 12, 13, 14, 15}, 640, ctlOf)
+	if !reflect.DeepEqual(actual, []int{11, 15}) {
+		t.Logf("Only clean previews in the same column should be reused: %v", actual)
+		t.Fail()
+	}
+	previewWindowsLock.Lock()
+	_, kept := previewWindows[14]
+	previewWindowsLock.Unlock()
+	if kept {
+		t.Log("A preview that was closed should be forgotten")
+		t.Fail()
+	}
+}
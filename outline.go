@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Symbol kinds worth showing in an outline. Entries whose kind we can't tell are kept.
+var outlineKinds = map[string]struct{}{
+	"Class":       {},
+	"Struct":      {},
+	"Interface":   {},
+	"Enum":        {},
+	"Namespace":   {},
+	"Function":    {},
+	"Method":      {},
+	"Constructor": {},
+}
+
+type OutlineEntry struct {
+	Location FileLocation
+	Kind     string
+	Name     string
+	Depth    int
+}
+
+// ycmd describes symbols as "Kind: name". Descriptions without a kind come back with an empty kind.
+func ParseSymbolDescription(description string) (string, string) {
+	sepIdx := strings.Index(description, ": ")
+	if sepIdx < 0 || strings.ContainsAny(description[:sepIdx], " \t") {
+		return "", strings.TrimSpace(description)
+	}
+	return description[:sepIdx], strings.TrimSpace(description[sepIdx+2:])
+}
+
+// Keep the symbols in path worth outlining, in file order, nested by column: an entry belongs to the closest entry
+// above it that starts in an earlier column. That's exact for Python and close enough for C++.
+func BuildOutline(path string, locations FileLocations) []OutlineEntry {
+	entries := make([]OutlineEntry, 0, len(locations))
+	for _, location := range locations {
+		if location.Filepath != path {
+			continue
+		}
+		kind, name := ParseSymbolDescription(location.Description)
+		if _, ok := outlineKinds[kind]; kind != "" && !ok {
+			continue
+		}
+		entries = append(entries, OutlineEntry{Location: location, Kind: kind, Name: name})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Location.LineNum != entries[j].Location.LineNum {
+			return entries[i].Location.LineNum < entries[j].Location.LineNum
+		}
+		return entries[i].Location.ColumnNum < entries[j].Location.ColumnNum
+	})
+	var columns []int
+	for i := range entries {
+		for len(columns) > 0 && columns[len(columns)-1] >= entries[i].Location.ColumnNum {
+			columns = columns[:len(columns)-1]
+		}
+		entries[i].Depth = len(columns)
+		columns = append(columns, entries[i].Location.ColumnNum)
+	}
+	return entries
+}
+
+// The index of the entry containing the given line, which is the last one starting at or before it. -1 if none does.
+func OutlineEntryContaining(entries []OutlineEntry, line int) int {
+	containing := -1
+	for i, entry := range entries {
+		if entry.Location.LineNum > line {
+			break
+		}
+		containing = i
+	}
+	return containing
+}
+
+// One entry per line, indented by nesting and followed by its clickable address. The entry containing dotLine is
+// marked with ">".
+func FormatOutline(path string, entries []OutlineEntry, dotLine int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Outline of %s: %d symbols\n\n", path, len(entries))
+	marked := OutlineEntryContaining(entries, dotLine)
+	for i, entry := range entries {
+		marker := " "
+		if i == marked {
+			marker = ">"
+		}
+		label := entry.Name
+		if entry.Kind != "" {
+			label = fmt.Sprintf("%s %s", strings.ToLower(entry.Kind), entry.Name)
+		}
+		fmt.Fprintf(&b, "%s %s%s\t%s\n", marker, strings.Repeat("    ", entry.Depth), label, entry.Location.String())
+	}
+	return b.String()
+}
+
+func OutlineWindowName(path string) string {
+	return path + "+Outline"
+}
+
+// Ask ycmd for the document outline, falling back to GoToSymbol with an empty query for completers without one, and
+// show it in the file's +Outline window.
+func (p *PythonIde) ShowOutline(ctx context.Context) error {
+	ycmdRequest, err := p.NewYcmdRequest("GoToDocumentOutline")
+	if err != nil {
+		return err
+	}
+	blob, err := PostHandler(ctx, "run_completer_command", ycmdRequest)
+	if err != nil {
+		log.Printf("GoToDocumentOutline failed, falling back to GoToSymbol: %s\n", err)
+		ycmdRequest.CommandArguments = []string{"GoToSymbol", ""}
+		blob, err = PostHandler(ctx, "run_completer_command", ycmdRequest)
+		if err != nil {
+			return err
+		}
+	}
+	locations, err := DecodeFileLocations(blob)
+	if err != nil {
+		return err
+	}
+	entries := BuildOutline(p.Name(), locations)
+	return AcmeReplaceWindowBody(OutlineWindowName(p.Name()), FormatOutline(p.Name(), entries, ycmdRequest.LineNum))
+}
+
+// Refresh the outline, but only if the user has one open.
+func (p *PythonIde) RefreshOutline(ctx context.Context) {
+	isOpen, err := AcmeFilepathIsAlreadyOpen(OutlineWindowName(p.Name()))
+	if err != nil || !isOpen {
+		return
+	}
+	err = p.ShowOutline(ctx)
+	if err != nil {
+		log.Printf("Error refreshing outline for %s: %s\n", p.Name(), err)
+	}
+}
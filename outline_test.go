@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildOutline(t *testing.T) {
+	locations := FileLocations{
+		{LineNum: 12, ColumnNum: 5, Filepath: "/tmp/wtf.py", Description: "Variable: styles"},
+		{LineNum: 6, ColumnNum: 1, Filepath: "/tmp/wtf.py", Description: "Class: IgorStyle"},
+		{LineNum: 20, ColumnNum: 5, Filepath: "/tmp/wtf.py", Description: "Method: render"},
+		{LineNum: 3, ColumnNum: 1, Filepath: "/tmp/other.py", Description: "Class: Other"},
+		{LineNum: 30, ColumnNum: 1, Filepath: "/tmp/wtf.py", Description: "main"},
+	}
+	entries := BuildOutline("/tmp/wtf.py", locations)
+	expected := strings.Join([]string{
+		"Outline of /tmp/wtf.py: 3 symbols",
+		"",
+		"  class IgorStyle\t/tmp/wtf.py:6:1",
+		">     method render\t/tmp/wtf.py:20:5",
+		"  main\t/tmp/wtf.py:30:1",
+		"",
+	}, "\n")
+	actual := FormatOutline("/tmp/wtf.py", entries, 25)
+	if actual != expected {
+		t.Logf("Expected:\n%s\nbut received:\n%s\n", expected, actual)
+		t.Fail()
+	}
+}
+
+func TestOutlineEntryContaining(t *testing.T) {
+	entries := []OutlineEntry{{Location: FileLocation{LineNum: 6}}, {Location: FileLocation{LineNum: 20}}}
+	if actual := OutlineEntryContaining(entries, 2); actual != -1 {
+		t.Logf("Expected no entry but received %d\n", actual)
+		t.Fail()
+	}
+	if actual := OutlineEntryContaining(entries, 6); actual != 0 {
+		t.Logf("Expected entry 0 but received %d\n", actual)
+		t.Fail()
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"9fans.net/go/acme"
+)
+
+// Which diagnostics Problems lists. Kind is "ERROR" or "WARNING", or empty for both. File, if set, has to be part of
+// the path.
+type ProblemsFilter struct {
+	Kind string
+	File string
+}
+
+// "Problems errors foo.cpp" lists the errors in files whose path contains foo.cpp.
+func ParseProblemsFilter(args []string) ProblemsFilter {
+	filter := ProblemsFilter{}
+	for _, arg := range args {
+		switch strings.ToLower(arg) {
+		case "error", "errors":
+			filter.Kind = "ERROR"
+		case "warning", "warnings":
+			filter.Kind = "WARNING"
+		default:
+			filter.File = arg
+		}
+	}
+	return filter
+}
+
+func (f ProblemsFilter) Matches(path string, d *Diagnostic) bool {
+	if f.Kind == "ERROR" && !d.IsError() || f.Kind == "WARNING" && d.IsError() {
+		return false
+	}
+	return strings.Contains(path, f.File)
+}
+
+func (f ProblemsFilter) String() string {
+	var parts []string
+	if f.Kind != "" {
+		parts = append(parts, strings.ToLower(f.Kind)+"s")
+	}
+	if f.File != "" {
+		parts = append(parts, fmt.Sprintf("in %s", f.File))
+	}
+	return strings.Join(parts, " ")
+}
+
+// The diagnostics of every file still open in a window that pass the filter, errors first, then by file and position.
+func CollectProblems(byFile map[string][]Diagnostic, isOpen func(path string) bool, filter ProblemsFilter) []Diagnostic {
+	var problems []Diagnostic
+	for path, diagnostics := range byFile {
+		if !isOpen(path) {
+			continue
+		}
+		for i := range diagnostics {
+			if filter.Matches(path, &diagnostics[i]) {
+				problems = append(problems, diagnostics[i])
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := &problems[i], &problems[j]
+		if a.IsError() != b.IsError() {
+			return a.IsError()
+		}
+		if a.Location.Filepath != b.Location.Filepath {
+			return a.Location.Filepath < b.Location.Filepath
+		}
+		if a.Location.LineNum != b.Location.LineNum {
+			return a.Location.LineNum < b.Location.LineNum
+		}
+		return a.Location.ColumnNum < b.Location.ColumnNum
+	})
+	return problems
+}
+
+func FormatProblems(problems []Diagnostic, filter ProblemsFilter) string {
+	errorCount, warningCount := CountDiagnostics(problems)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Problems in open windows: %s, %s", pluralise(errorCount, "error"), pluralise(warningCount, "warning"))
+	if description := filter.String(); description != "" {
+		fmt.Fprintf(&b, " (%s)", description)
+	}
+	fmt.Fprintf(&b, "\n\n")
+	for i := range problems {
+		fmt.Fprintf(&b, "%s\n", problems[i].String())
+	}
+	return b.String()
+}
+
+// A copy of the latest diagnostics for every file.
+func AllDiagnostics() map[string][]Diagnostic {
+	diagnosticsByFileLock.Lock()
+	defer diagnosticsByFileLock.Unlock()
+	byFile := make(map[string][]Diagnostic, len(diagnosticsByFile))
+	for path, diagnostics := range diagnosticsByFile {
+		byFile[path] = diagnostics
+	}
+	return byFile
+}
+
+// The names of every window acme has open, whatever kind of window watches it.
+func acmeWindowNames() (map[string]bool, error) {
+	windows, err := acme.Windows()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(windows))
+	for _, window := range windows {
+		names[window.Name] = true
+	}
+	return names, nil
+}
+
+// The +Problems windows we've filled in, with the filter each was asked for, so they can be kept current.
+var problemsViews = map[string]ProblemsFilter{}
+var problemsViewsLock sync.Mutex
+
+func ProblemsWindowName(winName string) string {
+	return ResultsWindowName(winName, "Problems")
+}
+
+func showProblems(windowName string, filter ProblemsFilter) error {
+	names, err := acmeWindowNames()
+	if err != nil {
+		return err
+	}
+	problems := CollectProblems(AllDiagnostics(), func(path string) bool { return names[path] }, filter)
+	return AcmeReplaceWindowBody(windowName, FormatProblems(problems, filter))
+}
+
+// Problems lists the diagnostics of all open windows in +Problems next to the window the command came from.
+func ShowProblems(winName string, args []string) error {
+	windowName := ProblemsWindowName(winName)
+	filter := ParseProblemsFilter(args)
+	problemsViewsLock.Lock()
+	problemsViews[windowName] = filter
+	problemsViewsLock.Unlock()
+	return showProblems(windowName, filter)
+}
+
+// Keep the open +Problems windows current, and forget the ones the user closed.
+func RefreshProblemsViews(path string, diagnostics []Diagnostic) {
+	problemsViewsLock.Lock()
+	views := make(map[string]ProblemsFilter, len(problemsViews))
+	for windowName, filter := range problemsViews {
+		views[windowName] = filter
+	}
+	problemsViewsLock.Unlock()
+	for windowName, filter := range views {
+		isOpen, err := AcmeFilepathIsAlreadyOpen(windowName)
+		if err != nil {
+			continue
+		}
+		if !isOpen {
+			problemsViewsLock.Lock()
+			delete(problemsViews, windowName)
+			problemsViewsLock.Unlock()
+			continue
+		}
+		err = showProblems(windowName, filter)
+		if err != nil {
+			log.Printf("Error refreshing %s: %s\n", windowName, err)
+		}
+	}
+}
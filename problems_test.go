@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+)
+
+func someProblems() map[string][]Diagnostic {
+	a, _ := DecodeDiagnostics([]byte(someDiagnostics))
+	b := []Diagnostic{
+		{Location: FileLocation{LineNum: 2, ColumnNum: 1, Filepath: "/src/b.py"}, Kind: "ERROR", Text: "invalid syntax"},
+	}
+	closed := []Diagnostic{
+		{Location: FileLocation{LineNum: 1, ColumnNum: 1, Filepath: "/src/closed.py"}, Kind: "ERROR", Text: "gone"},
+	}
+	return map[string][]Diagnostic{"/src/a.cpp": a, "/src/b.py": b, "/src/closed.py": closed}
+}
+
+func isOpenForTest(path string) bool {
+	return path != "/src/closed.py"
+}
+
+func TestCollectProblems(t *testing.T) {
+	problems := CollectProblems(someProblems(), isOpenForTest, ProblemsFilter{})
+	expected := "Problems in open windows: 2 errors, 1 warning\n\n" +
+		"/src/a.cpp:3:5\terror: use of undeclared identifier 'x'\n" +
+		"/src/b.py:2:1\terror: invalid syntax\n" +
+		"/src/a.cpp:7:9\twarning: unused variable 'y'\n"
+	if actual := FormatProblems(problems, ProblemsFilter{}); actual != expected {
+		t.Log(actual)
+		t.Fail()
+	}
+}
+
+func TestCollectProblemsFiltered(t *testing.T) {
+	filter := ParseProblemsFilter([]string{"Errors", "a.cpp"})
+	if filter.Kind != "ERROR" || filter.File != "a.cpp" {
+		t.Logf("%+v", filter)
+		t.FailNow()
+	}
+	problems := CollectProblems(someProblems(), isOpenForTest, filter)
+	expected := "Problems in open windows: 1 error, 0 warnings (errors in a.cpp)\n\n" +
+		"/src/a.cpp:3:5\terror: use of undeclared identifier 'x'\n"
+	if actual := FormatProblems(problems, filter); actual != expected {
+		t.Log(actual)
+		t.Fail()
+	}
+	problems = CollectProblems(someProblems(), isOpenForTest, ParseProblemsFilter([]string{"warning"}))
+	if len(problems) != 1 || problems[0].IsError() {
+		t.Logf("%+v", problems)
+		t.Fail()
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Something ycmd pushed to us: either a notice for the user, such as "Indexing 40%", or the diagnostics for a file.
+type YcmdMessage struct {
+	Message  string `json:"message"`
+	Filepath string `json:"filepath"`
+	// A pointer, because an empty list clears the file's diagnostics while a missing one means this isn't about them.
+	Diagnostics *[]Diagnostic `json:"diagnostics"`
+}
+
+// Decode a receive_messages reply. true means nothing happened and we should ask again; false means the completer
+// never has anything to say and we should stop.
+func DecodeMessages(blob []byte) ([]YcmdMessage, bool, error) {
+	var keepPolling bool
+	if err := json.Unmarshal(blob, &keepPolling); err == nil {
+		return nil, keepPolling, nil
+	}
+	var messages []YcmdMessage
+	err := json.Unmarshal(blob, &messages)
+	if err != nil {
+		return nil, false, err
+	}
+	return messages, true, nil
+}
+
+// The window ycmd's notices go to.
+func IdeWindowName() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "/"
+	}
+	return filepath.Join(dir, GlobalWindowSuffix)
+}
+
+func DispatchMessages(filetype string, messages []YcmdMessage) {
+	for _, message := range messages {
+		if message.Diagnostics != nil {
+			SetDiagnostics(message.Filepath, *message.Diagnostics)
+			continue
+		}
+		if message.Message == "" {
+			continue
+		}
+		err := AcmeAppendToWindow(IdeWindowName(), fmt.Sprintf("%s: %s\n", filetype, message.Message))
+		if err != nil {
+			log.Printf("Error showing message from %s: %s\n", filetype, err)
+		}
+	}
+}
+
+const messagePollRetryDelay = 5 * time.Second
+
+// The filetypes being polled for messages. Filetypes whose completer said it has none stay in here, set to false, so
+// they aren't polled again.
+var messagePollers = map[string]bool{}
+var messagePollersLock sync.Mutex
+
+// Whether the filetype's completer is being polled for messages, which is how it sends its diagnostics.
+func PushesMessages(filetype string) bool {
+	messagePollersLock.Lock()
+	defer messagePollersLock.Unlock()
+	return messagePollers[filetype]
+}
+
+// Start polling receive_messages for the filetype's completer, unless that's already happening.
+func StartMessagePoller(filetype string) {
+	messagePollersLock.Lock()
+	defer messagePollersLock.Unlock()
+	if _, ok := messagePollers[filetype]; ok {
+		return
+	}
+	messagePollers[filetype] = true
+	go pollMessages(filetype)
+}
+
+// Any open window of the filetype, or nil if there are none left.
+func openBufferOfFiletype(filetype string) *OpenBuffer {
+	openBuffersLock.Lock()
+	defer openBuffersLock.Unlock()
+	for _, buffer := range openBuffers {
+		if len(buffer.Filetypes) > 0 && buffer.Filetypes[0] == filetype {
+			return buffer
+		}
+	}
+	return nil
+}
+
+// ycmd picks the completer from the request's file, so poll on behalf of any open window of the filetype, sending
+// what's in the window.
+func messagePollRequest(filetype string) (*YcmdRequest, bool) {
+	buffer := openBufferOfFiletype(filetype)
+	if buffer == nil {
+		return nil, false
+	}
+	contents, dirty, err := buffer.unsavedContents()
+	if err != nil || !dirty {
+		blob, _ := ioutil.ReadFile(buffer.Name)
+		contents = string(blob)
+	}
+	return &YcmdRequest{
+		LineNum:      1,
+		ColumnNum:    1,
+		Filepath:     buffer.Name,
+		FileContents: contents,
+		Filetypes:    buffer.Filetypes,
+	}, true
+}
+
+// Long-poll receive_messages until the last window of the filetype closes or the completer says it has nothing to
+// send. ycmd holds each request open until it has something, or for a few seconds.
+func pollMessages(filetype string) {
+	for {
+		ycmdRequest, ok := messagePollRequest(filetype)
+		if !ok {
+			messagePollersLock.Lock()
+			delete(messagePollers, filetype)
+			messagePollersLock.Unlock()
+			return
+		}
+		blob, err := PostHandler(context.Background(), "receive_messages", ycmdRequest)
+		if err != nil {
+			log.Printf("receive_messages for %s: %s\n", filetype, err)
+			time.Sleep(messagePollRetryDelay)
+			continue
+		}
+		messages, keepPolling, err := DecodeMessages(blob)
+		if err != nil {
+			log.Printf("receive_messages for %s: %s\n", filetype, err)
+			time.Sleep(messagePollRetryDelay)
+			continue
+		}
+		if !keepPolling {
+			messagePollersLock.Lock()
+			messagePollers[filetype] = false
+			messagePollersLock.Unlock()
+			return
+		}
+		DispatchMessages(filetype, messages)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDecodeMessages(t *testing.T) {
+	for blob, expected := range map[string]bool{"true": true, "false": false} {
+		messages, keepPolling, err := DecodeMessages([]byte(blob))
+		if err != nil || messages != nil || keepPolling != expected {
+			t.Logf("%s: %v %t %v", blob, messages, keepPolling, err)
+			t.Fail()
+		}
+	}
+	blob := `[{"message": "Indexing 40%"}, {"filepath": "/src/a.cpp", "diagnostics": []}]`
+	messages, keepPolling, err := DecodeMessages([]byte(blob))
+	if err != nil || !keepPolling || len(messages) != 2 {
+		t.Logf("%v %t %v", messages, keepPolling, err)
+		t.FailNow()
+	}
+	if messages[0].Message != "Indexing 40%" || messages[0].Diagnostics != nil {
+		t.Logf("%+v", messages[0])
+		t.Fail()
+	}
+	if messages[1].Diagnostics == nil || len(*messages[1].Diagnostics) != 0 {
+		t.Log("An empty diagnostics list should still be there, to clear the file")
+		t.Fail()
+	}
+}
+
+func TestDispatchMessages(t *testing.T) {
+	handlers := DiagnosticsHandlers
+	DiagnosticsHandlers = nil
+	defer func() { DiagnosticsHandlers = handlers }()
+	blob := `[{"filepath": "/src/b.cpp", "diagnostics": ` + someDiagnostics + `}]`
+	messages, _, err := DecodeMessages([]byte(blob))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	DispatchMessages("cpp", messages)
+	defer SetDiagnostics("/src/b.cpp", nil)
+	if len(DiagnosticsFor("/src/b.cpp")) != 2 {
+		t.Log("Pushed diagnostics should be stored")
+		t.Fail()
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"9fans.net/go/acme"
+)
+
+// Results windows are named after the directory of the window the query came from, e.g. /src/project/+References.
+func ResultsWindowName(sourceName, kind string) string {
+	return filepath.Join(filepath.Dir(sourceName), "+"+kind)
+}
+
+// Find the acme window with the given name, or create one.
+func AcmeOpenOrCreateWindow(name string) (*acme.Win, error) {
+	windows, err := acme.Windows()
+	if err != nil {
+		return nil, err
+	}
+	for _, window := range windows {
+		if window.Name == name {
+			return acme.Open(window.ID, nil)
+		}
+	}
+	win, err := acme.New()
+	if err != nil {
+		return nil, err
+	}
+	err = win.Name(name)
+	if err != nil {
+		win.CloseFiles()
+		return nil, err
+	}
+	return win, nil
+}
+
+// Replace the body of the named window with contents, creating the window if needed, and show the top of it.
+func AcmeReplaceWindowBody(name, contents string) error {
+	return AcmeReplaceWindowBodyAt(name, contents, "#0")
+}
+
+// Like AcmeReplaceWindowBody, but show addr instead of the top.
+func AcmeReplaceWindowBodyAt(name, contents, addr string) error {
+	win, err := AcmeOpenOrCreateWindow(name)
+	if err != nil {
+		return err
+	}
+	defer win.CloseFiles()
+	err = win.Addr(",")
+	if err != nil {
+		return err
+	}
+	_, err = win.Write("data", []byte(contents))
+	if err != nil {
+		return err
+	}
+	win.Ctl("clean")
+	if win.Addr(addr) != nil {
+		win.Addr("#0")
+	}
+	win.Ctl("dot=addr")
+	win.Ctl("show")
+	return nil
+}
+
+// Add contents to the end of the named window, creating the window if needed, and show the end.
+func AcmeAppendToWindow(name, contents string) error {
+	win, err := AcmeOpenOrCreateWindow(name)
+	if err != nil {
+		return err
+	}
+	defer win.CloseFiles()
+	err = win.Addr("$")
+	if err != nil {
+		return err
+	}
+	_, err = win.Write("data", []byte(contents))
+	if err != nil {
+		return err
+	}
+	win.Ctl("clean")
+	win.Ctl("dot=addr")
+	win.Ctl("show")
+	return nil
+}
+
+// The lines of a file, preferring the contents of an open acme window over what's on disk so unsaved edits show up.
+func SourceLines(path string) ([]string, error) {
+	windows, err := acme.Windows()
+	if err == nil {
+		for _, window := range windows {
+			if window.Name != path {
+				continue
+			}
+			win, err := acme.Open(window.ID, nil)
+			if err != nil {
+				break
+			}
+			body, err := win.ReadAll("body")
+			win.CloseFiles()
+			if err != nil {
+				break
+			}
+			return strings.Split(string(body), "\n"), nil
+		}
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(blob), "\n"), nil
+}
+
+// Render locations grouped by file, with counts, as clickable file:line:col lines followed by the trimmed source line.
+// sourceLines is used to look up the source for each file; the ycmd description is used when it fails.
+func FormatResults(title string, locations FileLocations, sourceLines func(path string) ([]string, error)) string {
+	sorted := make(FileLocations, len(locations))
+	copy(sorted, locations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Filepath != sorted[j].Filepath {
+			return sorted[i].Filepath < sorted[j].Filepath
+		}
+		if sorted[i].LineNum != sorted[j].LineNum {
+			return sorted[i].LineNum < sorted[j].LineNum
+		}
+		return sorted[i].ColumnNum < sorted[j].ColumnNum
+	})
+	counts := map[string]int{}
+	for _, location := range sorted {
+		counts[location.Filepath]++
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d results in %d files\n", title, len(sorted), len(counts))
+	var (
+		currentPath string
+		lines       []string
+	)
+	for _, location := range sorted {
+		if location.Filepath != currentPath {
+			currentPath = location.Filepath
+			lines, _ = sourceLines(currentPath)
+			fmt.Fprintf(&b, "\n%s (%d)\n", currentPath, counts[currentPath])
+		}
+		text := strings.TrimSpace(location.Description)
+		if location.LineNum >= 1 && location.LineNum <= len(lines) {
+			text = strings.TrimSpace(lines[location.LineNum-1])
+		}
+		fmt.Fprintf(&b, "%s\t%s\n", location.String(), text)
+	}
+	return b.String()
+}
+
+// Show locations in the results window of the given kind, replacing the previous query's results.
+func ShowResults(sourceName, kind, title string, locations FileLocations) error {
+	return AcmeReplaceWindowBody(ResultsWindowName(sourceName, kind), FormatResults(title, locations, SourceLines))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFormatResults(t *testing.T) {
+	locations := FileLocations{
+		{LineNum: 3, ColumnNum: 9, Filepath: "/tmp/b.py", Description: "from b"},
+		{LineNum: 2, ColumnNum: 1, Filepath: "/tmp/a.py"},
+		{LineNum: 1, ColumnNum: 5, Filepath: "/tmp/b.py", Description: "  unread  "},
+	}
+	sourceLines := func(path string) ([]string, error) {
+		if path == "/tmp/a.py" {
+			return []string{"import os", "    os.getcwd()  "}, nil
+		}
+		return nil, errors.New("not found")
+	}
+	expected := strings.Join([]string{
+		"References: 3 results in 2 files",
+		"",
+		"/tmp/a.py (1)",
+		"/tmp/a.py:2:1\tos.getcwd()",
+		"",
+		"/tmp/b.py (2)",
+		"/tmp/b.py:1:5\tunread",
+		"/tmp/b.py:3:9\tfrom b",
+		"",
+	}, "\n")
+	actual := FormatResults("References", locations, sourceLines)
+	if actual != expected {
+		t.Logf("Expected:\n%s\nbut received:\n%s\n", expected, actual)
+		t.Fail()
+	}
+}
+
+func TestResultsWindowName(t *testing.T) {
+	actual := ResultsWindowName("/src/project/main.py", "References")
+	if actual != "/src/project/+References" {
+		t.Logf("Expected %s but received %s\n", "/src/project/+References", actual)
+		t.Fail()
+	}
+}
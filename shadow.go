@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// An in-memory copy of a window body, kept up to date from acme's insert and delete events so requests don't have to
+// read the whole body back through the file system. Offsets are in runes, like acme's.
+type ShadowBuffer struct {
+	runes []rune
+	// The rune offset each line starts at. Always has at least one entry, for the first line.
+	lineStarts []int
+}
+
+func NewShadowBuffer(text string) *ShadowBuffer {
+	b := &ShadowBuffer{}
+	b.Reset(text)
+	return b
+}
+
+func (b *ShadowBuffer) Reset(text string) {
+	b.runes = []rune(text)
+	b.lineStarts = lineStartsOf(b.runes, 0, []int{0})
+}
+
+// Append the offsets after each newline in runes, shifted by offset, to lineStarts.
+func lineStartsOf(runes []rune, offset int, lineStarts []int) []int {
+	for i, r := range runes {
+		if r == '\n' {
+			lineStarts = append(lineStarts, offset+i+1)
+		}
+	}
+	return lineStarts
+}
+
+func (b *ShadowBuffer) Len() int {
+	return len(b.runes)
+}
+
+func (b *ShadowBuffer) String() string {
+	return string(b.runes)
+}
+
+func (b *ShadowBuffer) Insert(q int, text []rune) error {
+	if q < 0 || q > len(b.runes) {
+		return errors.New(fmt.Sprintf("insert at #%d outside body of %d runes", q, len(b.runes)))
+	}
+	runes := make([]rune, 0, len(b.runes)+len(text))
+	runes = append(runes, b.runes[:q]...)
+	runes = append(runes, text...)
+	b.runes = append(runes, b.runes[q:]...)
+
+	// Lines starting at or before q stay put, the new text adds its own, and the rest move along.
+	line := b.lineIndex(q)
+	lineStarts := make([]int, 0, len(b.lineStarts)+len(text)/40+1)
+	lineStarts = append(lineStarts, b.lineStarts[:line+1]...)
+	lineStarts = lineStartsOf(text, q, lineStarts)
+	for _, start := range b.lineStarts[line+1:] {
+		lineStarts = append(lineStarts, start+len(text))
+	}
+	b.lineStarts = lineStarts
+	return nil
+}
+
+func (b *ShadowBuffer) Delete(q0, q1 int) error {
+	if q0 < 0 || q1 < q0 || q1 > len(b.runes) {
+		return errors.New(fmt.Sprintf("delete of #%d,#%d outside body of %d runes", q0, q1, len(b.runes)))
+	}
+	b.runes = append(b.runes[:q0], b.runes[q1:]...)
+
+	// Lines starting inside the deleted text go, the rest after it move back.
+	first := b.lineIndex(q0) + 1
+	kept := b.lineStarts[:first]
+	for _, start := range b.lineStarts[first:] {
+		if start <= q1 {
+			continue
+		}
+		kept = append(kept, start-(q1-q0))
+	}
+	b.lineStarts = kept
+	return nil
+}
+
+// The 0-based line containing rune offset q.
+func (b *ShadowBuffer) lineIndex(q int) int {
+	return sort.Search(len(b.lineStarts), func(i int) bool { return b.lineStarts[i] > q }) - 1
+}
+
+// The line and byte column of rune offset q, as ycmd counts them. Only the line containing q is scanned.
+func (b *ShadowBuffer) LineAndColumn(q int) *LineAndColumn {
+	if q > len(b.runes) {
+		q = len(b.runes)
+	}
+	if q < 0 {
+		q = 0
+	}
+	line := b.lineIndex(q)
+	column := 1
+	for _, r := range b.runes[b.lineStarts[line]:q] {
+		column += utf8.RuneLen(r)
+	}
+	return &LineAndColumn{Line: line + 1, Column: column}
+}
+
+func (b *ShadowBuffer) LineCount() int {
+	return len(b.lineStarts)
+}
+
+// The text between rune offsets q0 and q1.
+func (b *ShadowBuffer) Slice(q0, q1 int) string {
+	if q0 < 0 {
+		q0 = 0
+	}
+	if q1 > len(b.runes) {
+		q1 = len(b.runes)
+	}
+	if q1 < q0 {
+		return ""
+	}
+	return string(b.runes[q0:q1])
+}
+
+func (b *ShadowBuffer) Checksum() [sha256.Size]byte {
+	return sha256.Sum256([]byte(string(b.runes)))
+}
+
+// Apply an insert or delete event from acme. An error means the shadow can no longer be trusted and should be
+// resynced from the body.
+func (b *ShadowBuffer) ApplyEvent(c2 rune, q0, q1 int, text []byte) error {
+	switch c2 {
+	case 'I':
+		runes := []rune(string(text))
+		// acme leaves the text out of events when it's long.
+		if len(runes) != q1-q0 {
+			return errors.New(fmt.Sprintf("insert of #%d,#%d came with %d runes", q0, q1, len(runes)))
+		}
+		return b.Insert(q0, runes)
+	case 'D':
+		return b.Delete(q0, q1)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestShadowBuffer_LineAndColumn(t *testing.T) {
+	text := "a\nβc\n\nd"
+	b := NewShadowBuffer(text)
+	runes := []rune(text)
+	for q := 0; q <= len(runes); q++ {
+		expected := LineAndColumnOfRuneOffset(runes, q)
+		if actual := b.LineAndColumn(q); *actual != *expected {
+			t.Logf("#%d: expected %+v, got %+v", q, expected, actual)
+			t.Fail()
+		}
+	}
+	if b.LineCount() != 4 {
+		t.Logf("LineCount: %d", b.LineCount())
+		t.Fail()
+	}
+}
+
+func TestShadowBuffer_InsertDelete(t *testing.T) {
+	b := NewShadowBuffer("one\ntwo\nthree\n")
+	err := b.Insert(4, []rune("un\ndeux\n"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if b.String() != "one\nun\ndeux\ntwo\nthree\n" {
+		t.Logf("%q", b.String())
+		t.FailNow()
+	}
+	err = b.Delete(2, 8)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if b.String() != "oneux\ntwo\nthree\n" {
+		t.Logf("%q", b.String())
+		t.FailNow()
+	}
+	if lc := b.LineAndColumn(6); lc.Line != 2 || lc.Column != 1 {
+		t.Logf("%+v", lc)
+		t.Fail()
+	}
+	if b.Insert(100, []rune("x")) == nil || b.Delete(3, 100) == nil {
+		t.Log("Edits outside the body should be errors")
+		t.Fail()
+	}
+}
+
+// Random edits should leave the line index the same as one built from scratch.
+func TestShadowBuffer_RandomEdits(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	pieces := []string{"x", "\n", "é", "ab\ncd", "\n\n", "日本"}
+	b := NewShadowBuffer("")
+	for i := 0; i < 500; i++ {
+		if b.Len() > 0 && r.Intn(3) == 0 {
+			q0 := r.Intn(b.Len())
+			q1 := q0 + r.Intn(b.Len()-q0+1)
+			b.Delete(q0, q1)
+		} else {
+			b.Insert(r.Intn(b.Len()+1), []rune(pieces[r.Intn(len(pieces))]))
+		}
+		fresh := NewShadowBuffer(b.String())
+		if len(fresh.lineStarts) != len(b.lineStarts) {
+			t.Logf("step %d: %v != %v", i, b.lineStarts, fresh.lineStarts)
+			t.FailNow()
+		}
+		for j := range fresh.lineStarts {
+			if fresh.lineStarts[j] != b.lineStarts[j] {
+				t.Logf("step %d: %v != %v", i, b.lineStarts, fresh.lineStarts)
+				t.FailNow()
+			}
+		}
+	}
+	if b.Checksum() != NewShadowBuffer(b.String()).Checksum() {
+		t.Log("Checksum should only depend on the text")
+		t.Fail()
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"9fans.net/go/acme"
+)
+
+// A parameter label is either the text of the parameter, or a [start, end) offset pair into the signature label.
+type SignatureParameter struct {
+	Label json.RawMessage `json:"label"`
+}
+
+type Signature struct {
+	Label      string               `json:"label"`
+	Parameters []SignatureParameter `json:"parameters"`
+}
+
+type SignatureHelp struct {
+	ActiveSignature int         `json:"activeSignature"`
+	ActiveParameter int         `json:"activeParameter"`
+	Signatures      []Signature `json:"signatures"`
+}
+
+type SignatureHelpResponse struct {
+	SignatureHelp SignatureHelp `json:"signature_help"`
+}
+
+// Where the parameter sits in the signature label, in runes. ok is false if it can't be found.
+func (s *Signature) ParameterRange(parameter int) (int, int, bool) {
+	if parameter < 0 || parameter >= len(s.Parameters) {
+		return 0, 0, false
+	}
+	label := []rune(s.Label)
+	var offsets []int
+	if err := json.Unmarshal(s.Parameters[parameter].Label, &offsets); err == nil {
+		if len(offsets) != 2 || offsets[0] < 0 || offsets[0] > offsets[1] || offsets[1] > len(label) {
+			return 0, 0, false
+		}
+		return offsets[0], offsets[1], true
+	}
+	var text string
+	if err := json.Unmarshal(s.Parameters[parameter].Label, &text); err != nil || text == "" {
+		return 0, 0, false
+	}
+	// Skip past the name so a parameter called like the function isn't matched there.
+	searchFrom := strings.IndexRune(s.Label, '(')
+	if searchFrom < 0 {
+		searchFrom = 0
+	}
+	idx := strings.Index(s.Label[searchFrom:], text)
+	if idx < 0 {
+		return 0, 0, false
+	}
+	start := len([]rune(s.Label[:searchFrom+idx]))
+	return start, start + len([]rune(text)), true
+}
+
+// One signature per line, the active one marked with ">" and its active parameter wrapped in «».
+func FormatSignatureHelp(help *SignatureHelp) string {
+	var b strings.Builder
+	for i, signature := range help.Signatures {
+		marker := " "
+		label := signature.Label
+		if i == help.ActiveSignature {
+			marker = ">"
+			if start, end, ok := signature.ParameterRange(help.ActiveParameter); ok {
+				runes := []rune(label)
+				label = fmt.Sprintf("%s«%s»%s", string(runes[:start]), string(runes[start:end]), string(runes[end:]))
+			}
+		}
+		fmt.Fprintf(&b, "%s %s\n", marker, label)
+	}
+	return b.String()
+}
+
+// The signatures of the call dot is in, which are empty outside a call.
+func (p *PythonIde) fetchSignatureHelp(ctx context.Context) (*SignatureHelp, *YcmdRequest, error) {
+	ycmdRequest, err := p.NewYcmdRequest()
+	if err != nil {
+		return nil, nil, err
+	}
+	blob, err := PostHandler(ctx, "signature_help", ycmdRequest)
+	if err != nil {
+		return nil, nil, err
+	}
+	response := SignatureHelpResponse{}
+	err = json.Unmarshal(blob, &response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &response.SignatureHelp, ycmdRequest, nil
+}
+
+func (p *PythonIde) ShowSignatureHelp(ctx context.Context) error {
+	help, ycmdRequest, err := p.fetchSignatureHelp(ctx)
+	if err != nil {
+		return err
+	}
+	if len(help.Signatures) == 0 {
+		return errors.New(fmt.Sprintf("no signature help at %s:%d:%d", p.Name(), ycmdRequest.LineNum,
+			ycmdRequest.ColumnNum))
+	}
+	p.showingSignature = true
+	return AcmeReplaceWindowBody(ResultsWindowName(p.Name(), "Doc"), FormatSignatureHelp(help))
+}
+
+// Empty the +Doc window, unless the user has closed it already.
+func (p *PythonIde) ClearSignatureHelp() error {
+	p.showingSignature = false
+	docName := ResultsWindowName(p.Name(), "Doc")
+	open, err := AcmeFilepathIsAlreadyOpen(docName)
+	if err != nil || !open {
+		return err
+	}
+	return AcmeReplaceWindowBody(docName, "")
+}
+
+// A ")" may only close a nested call, so ask again: the help is cleared once dot is outside every call, and shows the
+// outer call's signature otherwise. A +Doc window the user closed isn't opened again.
+func (p *PythonIde) RefreshSignatureHelp(ctx context.Context) error {
+	open, err := AcmeFilepathIsAlreadyOpen(ResultsWindowName(p.Name(), "Doc"))
+	if err != nil {
+		return err
+	}
+	if !open {
+		p.showingSignature = false
+		return nil
+	}
+	help, _, err := p.fetchSignatureHelp(ctx)
+	if err != nil {
+		return err
+	}
+	if len(help.Signatures) == 0 {
+		return p.ClearSignatureHelp()
+	}
+	return AcmeReplaceWindowBody(ResultsWindowName(p.Name(), "Doc"), FormatSignatureHelp(help))
+}
+
+// Typing "(" or "," shows the signature of the call we're in, when signature_help_auto is on. Typing ")" updates it
+// for the call dot is still in, if any. Returns the character typed, and false for events that don't touch signature
+// help.
+func SignatureHelpTrigger(e *acme.Event) (string, bool) {
+	if e.C1 != 'K' || e.C2 != 'I' {
+		return "", false
+	}
+	settings := GetIdeSettings()
+	if settings == nil || !settings.SignatureHelpAuto {
+		return "", false
+	}
+	switch text := string(e.Text); text {
+	case "(", ",", ")":
+		return text, true
+	}
+	return "", false
+}
+
+// Unlike Sig, a "(" typed outside a call, e.g. in (a + b), isn't an error: there's just nothing to show.
+func (p *PythonIde) UpdateSignatureHelp(ctx context.Context, trigger string) error {
+	if trigger == ")" {
+		if !p.showingSignature {
+			return nil
+		}
+		return p.RefreshSignatureHelp(ctx)
+	}
+	help, _, err := p.fetchSignatureHelp(ctx)
+	if err != nil || len(help.Signatures) == 0 {
+		return err
+	}
+	p.showingSignature = true
+	return AcmeReplaceWindowBody(ResultsWindowName(p.Name(), "Doc"), FormatSignatureHelp(help))
+}
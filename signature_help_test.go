@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const SignatureHelpJson = `{"errors": [], "signature_help": {"activeSignature": 1, "activeParameter": 1, "signatures": [
+	{"label": "render(self)", "parameters": [{"label": "self"}]},
+	{"label": "render(self, style, out=None)", "parameters": [{"label": [7, 11]}, {"label": [13, 18]}, {"label": "out=None"}]}
+]}}`
+
+func TestFormatSignatureHelp(t *testing.T) {
+	response := SignatureHelpResponse{}
+	err := json.Unmarshal([]byte(SignatureHelpJson), &response)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	expected := "  render(self)\n> render(self, «style», out=None)\n"
+	actual := FormatSignatureHelp(&response.SignatureHelp)
+	if actual != expected {
+		t.Logf("Expected:\n%s\nbut received:\n%s\n", expected, actual)
+		t.Fail()
+	}
+}
+
+func TestSignatureParameterRangeByText(t *testing.T) {
+	response := SignatureHelpResponse{}
+	err := json.Unmarshal([]byte(SignatureHelpJson), &response)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	start, end, ok := response.SignatureHelp.Signatures[1].ParameterRange(2)
+	if !ok || start != 20 || end != 28 {
+		t.Logf("Expected 20, 28 but received %d, %d (%t)\n", start, end, ok)
+		t.Fail()
+	}
+}
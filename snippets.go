@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"9fans.net/go/acme"
+)
+
+// A snippet from the user's snippet files. The body marks tab stops with ${1:placeholder}, ${1} or $1, visited in
+// number order, with $0 last.
+type Snippet struct {
+	Trigger     string
+	Description string
+	Body        string
+}
+
+// Where the user's snippets for a filetype live, in UltiSnips' layout:
+//
+//	snippet for "for loop"
+//	for ${1:x} in ${2:xs}:
+//	    ${0:pass}
+//	endsnippet
+func SnippetsPath(filetype string) string {
+	configDir := ConfigDir()
+	if configDir == "" {
+		return ""
+	}
+	return filepath.Join(configDir, "snippets", filetype+".snippets")
+}
+
+func ParseSnippets(contents string) ([]Snippet, error) {
+	var snippets []Snippet
+	var current *Snippet
+	var body []string
+	for i, line := range strings.Split(contents, "\n") {
+		if current != nil {
+			if strings.TrimRight(line, " \t") == "endsnippet" {
+				current.Body = strings.Join(body, "\n")
+				snippets = append(snippets, *current)
+				current = nil
+				continue
+			}
+			body = append(body, line)
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		fields := strings.Fields(trimmed)
+		if fields[0] != "snippet" || len(fields) < 2 {
+			return nil, errors.New(fmt.Sprintf("line %d: expected \"snippet trigger\", found %q", i+1, trimmed))
+		}
+		description := strings.TrimSpace(strings.TrimSpace(trimmed[len("snippet"):])[len(fields[1]):])
+		current = &Snippet{Trigger: fields[1], Description: strings.Trim(description, `"`)}
+		body = nil
+	}
+	if current != nil {
+		return nil, errors.New(fmt.Sprintf("snippet %s has no endsnippet", current.Trigger))
+	}
+	return snippets, nil
+}
+
+// The user's snippets for the filetype. The file is read each time, so edits apply to the next window opened.
+func SnippetsFor(filetype string) []Snippet {
+	path := SnippetsPath(filetype)
+	if path == "" {
+		return nil
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading %s: %s\n", path, err)
+		}
+		return nil
+	}
+	snippets, err := ParseSnippets(string(blob))
+	if err != nil {
+		log.Printf("Error reading %s: %s\n", path, err)
+		return nil
+	}
+	return snippets
+}
+
+// The snippets as ycmd's UltiSnips completer wants them in BufferVisit, so their triggers show up as completions.
+func UltiSnipsSnippets(snippets []Snippet) []map[string]string {
+	ultiSnips := make([]map[string]string, 0, len(snippets))
+	for _, snippet := range snippets {
+		description := snippet.Description
+		if description == "" {
+			description = snippet.Trigger
+		}
+		ultiSnips = append(ultiSnips, map[string]string{"trigger": snippet.Trigger, "description": description})
+	}
+	return ultiSnips
+}
+
+// The longest trigger that the text before dot ends with, and where it starts, in runes. -1 if there's none.
+func FindSnippetTrigger(before []rune, snippets []Snippet) (*Snippet, int) {
+	start := len(before)
+	for start > 0 && !unicode.IsSpace(before[start-1]) {
+		start--
+	}
+	for ; start < len(before); start++ {
+		word := string(before[start:])
+		for i := range snippets {
+			if snippets[i].Trigger == word {
+				return &snippets[i], start
+			}
+		}
+	}
+	return nil, -1
+}
+
+// A tab stop in expanded snippet text, in runes.
+type SnippetStop struct {
+	Number int
+	Start  int
+	End    int
+}
+
+var snippetStopPattern = regexp.MustCompile(`\$\{(\d+)(?::([^}]*))?\}|\$(\d+)`)
+
+// The text of the snippet with its stops replaced by their placeholders, and the stops in the order they're visited.
+// Lines after the first get indent, so the snippet lines up with where it was expanded. A stop number used more than
+// once is only visited the first time.
+func ExpandSnippet(body, indent string) (string, []SnippetStop) {
+	body = strings.ReplaceAll(body, "\n", "\n"+indent)
+	var b strings.Builder
+	var stops []SnippetStop
+	seen := map[int]bool{}
+	offset := 0
+	last := 0
+	for _, match := range snippetStopPattern.FindAllStringSubmatchIndex(body, -1) {
+		literal := body[last:match[0]]
+		b.WriteString(literal)
+		offset += len([]rune(literal))
+		last = match[1]
+		var number int
+		var placeholder string
+		if match[2] >= 0 {
+			number, _ = strconv.Atoi(body[match[2]:match[3]])
+			if match[4] >= 0 {
+				placeholder = body[match[4]:match[5]]
+			}
+		} else {
+			number, _ = strconv.Atoi(body[match[6]:match[7]])
+		}
+		b.WriteString(placeholder)
+		length := len([]rune(placeholder))
+		if !seen[number] {
+			seen[number] = true
+			stops = append(stops, SnippetStop{Number: number, Start: offset, End: offset + length})
+		}
+		offset += length
+	}
+	b.WriteString(body[last:])
+	sort.SliceStable(stops, func(i, j int) bool {
+		if stops[i].Number == 0 || stops[j].Number == 0 {
+			return stops[j].Number == 0 && stops[i].Number != 0
+		}
+		return stops[i].Number < stops[j].Number
+	})
+	return b.String(), stops
+}
+
+// The stops of a window's last expanded snippet, which move as the body is edited. Next is the stop Snip selects next,
+// so the one before it is being filled in.
+type ActiveSnippet struct {
+	Stops []SnippetStop
+	Next  int
+	// The inserts and deletes that writing the snippet itself will cause, which the stops already account for.
+	expansion []snippetEdit
+}
+
+type snippetEdit struct {
+	c2     rune
+	q0, q1 int
+}
+
+func moveSnippetOffset(q, q0, q1 int) int {
+	if q >= q1 {
+		return q - (q1 - q0)
+	}
+	if q > q0 {
+		return q0
+	}
+	return q
+}
+
+// Move the stops for text inserted or deleted between q0 and q1. Text inserted inside a stop becomes part of it, and so
+// does text inserted at either end of the stop being filled in. Other stops there, like the ${2} in ${1}${2}, are
+// pushed along.
+func (s *ActiveSnippet) ApplyEvent(c2 rune, q0, q1 int) {
+	for i := range s.Stops {
+		stop := &s.Stops[i]
+		switch c2 {
+		case 'I':
+			filling := i == s.Next-1
+			if q0 < stop.Start || q0 == stop.Start && !filling {
+				stop.Start += q1 - q0
+				stop.End += q1 - q0
+			} else if q0 < stop.End || q0 == stop.End && filling {
+				stop.End += q1 - q0
+			}
+		case 'D':
+			stop.Start = moveSnippetOffset(stop.Start, q0, q1)
+			stop.End = moveSnippetOffset(stop.End, q0, q1)
+		}
+	}
+}
+
+var activeSnippets = map[int]*ActiveSnippet{}
+var activeSnippetsLock sync.Mutex
+
+// Whether the event is the next edit from writing the snippet, which is then crossed off.
+func (s *ActiveSnippet) isExpansion(e *acme.Event) bool {
+	if len(s.expansion) == 0 || e.C1 == 'K' || e.C1 == 'M' {
+		return false
+	}
+	edit := s.expansion[0]
+	if edit.c2 != e.C2 || edit.q0 != e.Q0 || edit.q1 != e.Q1 {
+		return false
+	}
+	s.expansion = s.expansion[1:]
+	return true
+}
+
+// Keep the window's snippet stops in step with edits to the body, whether typed or made by a FixIt or formatting.
+// Only the edits writing the snippet are left out, since the stops are placed after them.
+func AdjustSnippetFromEvent(winId int, e *acme.Event) {
+	if !IsBodyChangeEvent(e) {
+		return
+	}
+	activeSnippetsLock.Lock()
+	defer activeSnippetsLock.Unlock()
+	if snippet, ok := activeSnippets[winId]; ok && !snippet.isExpansion(e) {
+		snippet.ApplyEvent(e.C2, e.Q0, e.Q1)
+	}
+}
+
+// Take the window's next snippet stop, forgetting the snippet once they've all been visited.
+func NextSnippetStop(winId int) (SnippetStop, bool) {
+	activeSnippetsLock.Lock()
+	defer activeSnippetsLock.Unlock()
+	snippet, ok := activeSnippets[winId]
+	if !ok || snippet.Next >= len(snippet.Stops) {
+		delete(activeSnippets, winId)
+		return SnippetStop{}, false
+	}
+	stop := snippet.Stops[snippet.Next]
+	snippet.Next++
+	if snippet.Next == len(snippet.Stops) {
+		delete(activeSnippets, winId)
+	}
+	return stop, true
+}
+
+func ForgetSnippet(winId int) {
+	activeSnippetsLock.Lock()
+	defer activeSnippetsLock.Unlock()
+	delete(activeSnippets, winId)
+}
+
+func (p *PythonIde) selectRange(q0, q1 int) error {
+	err := p.acmeWin.Addr("#%d,#%d", q0, q1)
+	if err != nil {
+		return err
+	}
+	p.acmeWin.Ctl("dot=addr")
+	p.acmeWin.Ctl("show")
+	return nil
+}
+
+// Snip selects the next stop of the snippet being filled in. Otherwise it expands the snippet whose trigger is selected
+// or just before dot, which is what choosing a snippet among the completions inserts, and selects its first stop.
+func (p *PythonIde) Snip(ctx context.Context) error {
+	if stop, ok := NextSnippetStop(p.Id()); ok {
+		return p.selectRange(stop.Start, stop.End)
+	}
+	filetypes := p.Filetypes()
+	if len(filetypes) == 0 {
+		return errors.New(fmt.Sprintf("no snippets for %s", p.Name()))
+	}
+	snippets := SnippetsFor(filetypes[0])
+	body, err := GetAcmeWindowBody(p.acmeWin)
+	if err != nil {
+		return err
+	}
+	dot, err := GetWinDot(p.acmeWin, p.Name())
+	if err != nil {
+		return err
+	}
+	runes := []rune(body)
+	if dot.Q0 > dot.Q1 || dot.Q1 > len(runes) {
+		return errors.New(fmt.Sprintf("dot is outside the body: #%d,#%d", dot.Q0, dot.Q1))
+	}
+	snippet, start := FindSnippetTrigger(runes[:dot.Q1], snippets)
+	if snippet == nil || dot.Q0 < dot.Q1 && start != dot.Q0 {
+		return errors.New(fmt.Sprintf("no %s snippet before #%d in %s", filetypes[0], dot.Q1, SnippetsPath(filetypes[0])))
+	}
+	lineStart := start
+	for lineStart > 0 && runes[lineStart-1] != '\n' {
+		lineStart--
+	}
+	indentEnd := lineStart
+	for indentEnd < start && (runes[indentEnd] == ' ' || runes[indentEnd] == '\t') {
+		indentEnd++
+	}
+	text, stops := ExpandSnippet(snippet.Body, string(runes[lineStart:indentEnd]))
+	err = p.acmeWin.Addr("#%d,#%d", start, dot.Q1)
+	if err != nil {
+		return err
+	}
+	end := start + len([]rune(text))
+	for i := range stops {
+		stops[i].Start += start
+		stops[i].End += start
+	}
+	if len(stops) > 1 {
+		// Registered before the write, so none of its events can arrive before the snippet is there to skip them.
+		var expansion []snippetEdit
+		if start < dot.Q1 {
+			expansion = append(expansion, snippetEdit{'D', start, dot.Q1})
+		}
+		if start < end {
+			expansion = append(expansion, snippetEdit{'I', start, end})
+		}
+		activeSnippetsLock.Lock()
+		activeSnippets[p.Id()] = &ActiveSnippet{Stops: stops, Next: 1, expansion: expansion}
+		activeSnippetsLock.Unlock()
+	}
+	_, err = p.acmeWin.Write("data", []byte(text))
+	if err != nil {
+		ForgetSnippet(p.Id())
+		return err
+	}
+	if len(stops) == 0 {
+		return p.selectRange(end, end)
+	}
+	return p.selectRange(stops[0].Start, stops[0].End)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"9fans.net/go/acme"
+)
+
+const someSnippets = `# Python snippets
+snippet for "for loop"
+for ${1:x} in ${2:xs}:
+    ${0:pass}
+endsnippet
+
+snippet main
+if __name__ == "__main__":
+    $1
+endsnippet
+`
+
+func TestParseSnippets(t *testing.T) {
+	snippets, err := ParseSnippets(someSnippets)
+	if err != nil || len(snippets) != 2 {
+		t.Logf("%+v %s", snippets, err)
+		t.FailNow()
+	}
+	expected := Snippet{Trigger: "for", Description: "for loop", Body: "for ${1:x} in ${2:xs}:\n    ${0:pass}"}
+	if snippets[0] != expected {
+		t.Logf("%+v", snippets[0])
+		t.Fail()
+	}
+	ultiSnips := UltiSnipsSnippets(snippets)
+	if ultiSnips[0]["description"] != "for loop" || ultiSnips[1]["description"] != "main" {
+		t.Logf("%+v", ultiSnips)
+		t.Fail()
+	}
+	if _, err := ParseSnippets("snippet for\nfor\n"); err == nil {
+		t.Log("A snippet without endsnippet should be an error")
+		t.Fail()
+	}
+	if _, err := ParseSnippets("for\n"); err == nil {
+		t.Log("Text outside a snippet should be an error")
+		t.Fail()
+	}
+}
+
+func TestExpandSnippet(t *testing.T) {
+	text, stops := ExpandSnippet("for ${1:x} in ${2:xs}:\n    ${0:pass} $1", "  ")
+	if text != "for x in xs:\n      pass " {
+		t.Logf("%q", text)
+		t.Fail()
+	}
+	expected := []SnippetStop{{1, 4, 5}, {2, 9, 11}, {0, 19, 23}}
+	if !reflect.DeepEqual(stops, expected) {
+		t.Logf("%+v", stops)
+		t.Fail()
+	}
+}
+
+func TestFindSnippetTrigger(t *testing.T) {
+	snippets, _ := ParseSnippets(someSnippets)
+	snippet, start := FindSnippetTrigger([]rune("    x = (for"), snippets)
+	if snippet == nil || snippet.Trigger != "for" || start != 9 {
+		t.Logf("%+v %d", snippet, start)
+		t.Fail()
+	}
+	if snippet, _ := FindSnippetTrigger([]rune("format"), snippets); snippet != nil {
+		t.Logf("%+v", snippet)
+		t.Fail()
+	}
+}
+
+func TestActiveSnippetApplyEvent(t *testing.T) {
+	snippet := &ActiveSnippet{Stops: []SnippetStop{{1, 4, 5}, {2, 9, 11}}, Next: 1}
+	// Typing "item" over the selected x: acme deletes the selection, then inserts.
+	snippet.ApplyEvent('D', 4, 5)
+	snippet.ApplyEvent('I', 4, 8)
+	expected := []SnippetStop{{1, 4, 8}, {2, 12, 14}}
+	if !reflect.DeepEqual(snippet.Stops, expected) {
+		t.Logf("%+v", snippet.Stops)
+		t.Fail()
+	}
+	// ${1}${2}${0}: what's typed into 1 pushes the others along rather than going into them too.
+	snippet = &ActiveSnippet{Stops: []SnippetStop{{1, 4, 4}, {2, 4, 4}, {0, 4, 4}}, Next: 1}
+	snippet.ApplyEvent('I', 4, 6)
+	expected = []SnippetStop{{1, 4, 6}, {2, 6, 6}, {0, 6, 6}}
+	if !reflect.DeepEqual(snippet.Stops, expected) {
+		t.Logf("%+v", snippet.Stops)
+		t.Fail()
+	}
+}
+
+func TestAdjustSnippetFromEvent(t *testing.T) {
+	activeSnippetsLock.Lock()
+	activeSnippets[3002] = &ActiveSnippet{Stops: []SnippetStop{{1, 4, 5}, {2, 9, 11}}, Next: 1,
+		expansion: []snippetEdit{{'D', 0, 3}, {'I', 0, 12}}}
+	activeSnippetsLock.Unlock()
+	defer ForgetSnippet(3002)
+	// Writing the snippet over its trigger doesn't move the stops, but a FixIt above it afterwards does.
+	AdjustSnippetFromEvent(3002, &acme.Event{C1: 'E', C2: 'D', Q0: 0, Q1: 3})
+	AdjustSnippetFromEvent(3002, &acme.Event{C1: 'E', C2: 'I', Q0: 0, Q1: 12})
+	AdjustSnippetFromEvent(3002, &acme.Event{C1: 'E', C2: 'I', Q0: 0, Q1: 2})
+	activeSnippetsLock.Lock()
+	defer activeSnippetsLock.Unlock()
+	expected := []SnippetStop{{1, 6, 7}, {2, 11, 13}}
+	if actual := activeSnippets[3002].Stops; !reflect.DeepEqual(actual, expected) {
+		t.Logf("%+v", actual)
+		t.Fail()
+	}
+}
+
+func TestNextSnippetStop(t *testing.T) {
+	activeSnippetsLock.Lock()
+	activeSnippets[3001] = &ActiveSnippet{Stops: []SnippetStop{{2, 9, 11}, {0, 19, 23}}}
+	activeSnippetsLock.Unlock()
+	for _, expected := range []int{2, 0} {
+		stop, ok := NextSnippetStop(3001)
+		if !ok || stop.Number != expected {
+			t.Logf("%+v %t", stop, ok)
+			t.Fail()
+		}
+	}
+	if _, ok := NextSnippetStop(3001); ok {
+		t.Log("The snippet should be forgotten after its last stop")
+		t.Fail()
+	}
+}
+
+func TestEventNotificationSnippets(t *testing.T) {
+	home, err := ioutil.TempDir("", "acmeide")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+	t.Setenv("HOME", home)
+	err = os.MkdirAll(filepath.Dir(SnippetsPath("python")), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(SnippetsPath("python"), []byte(someSnippets), 0644)
+	}
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer UpdateCurrentSettings(DefaultSettings())
+	ide := NewPythonIde(3003, "/src/a.py")
+	settings := DefaultSettings()
+	UpdateCurrentSettings(settings)
+	if _, ok := ide.EventNotificationFields("BufferVisit")["ultisnips_snippets"]; !ok {
+		t.Log("Snippets should be sent while use_ultisnips_completer is on")
+		t.Fail()
+	}
+	settings = DefaultSettings()
+	settings.UseUltiSnipsCompleter = 0
+	UpdateCurrentSettings(settings)
+	if _, ok := ide.EventNotificationFields("BufferVisit")["ultisnips_snippets"]; ok {
+		t.Log("Snippets shouldn't be sent while use_ultisnips_completer is off")
+		t.Fail()
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Files that mark the root of a project, and the filetype such a project is mostly written in. Checked in order in
+// each directory, walking up from where the search started.
+var projectMarkers = []struct {
+	Name     string
+	Filetype string
+}{
+	{".ycm_extra_conf.py", "cpp"},
+	{"compile_commands.json", "cpp"},
+	{"go.mod", "go"},
+	{"Cargo.toml", "rust"},
+	{"pom.xml", "java"},
+	{"build.gradle", "java"},
+	{"package.json", "javascript"},
+	{"tsconfig.json", "typescript"},
+	{"pyproject.toml", "python"},
+	{"setup.py", "python"},
+}
+
+// The filetype of the project containing dir, falling back to the default_filetype setting.
+func ProjectFiletype(dir string) string {
+	for {
+		for _, marker := range projectMarkers {
+			if _, err := os.Stat(filepath.Join(dir, marker.Name)); err == nil {
+				return marker.Filetype
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	if settings := GetIdeSettings(); settings != nil && settings.DefaultFiletype != "" {
+		return settings.DefaultFiletype
+	}
+	return "python"
+}
+
+// A request on behalf of a window that isn't a source file, such as a directory listing. ycmd needs a real file to pick
+// a completer and find the project, so use one of the right filetype from dir, or else an open window of that filetype.
+func NewProjectYcmdRequest(dir, filetype string, commandArguments ...string) (*YcmdRequest, error) {
+	ycmdRequest := &YcmdRequest{
+		LineNum:          1,
+		ColumnNum:        1,
+		Filetypes:        []string{filetype},
+		CommandArguments: commandArguments,
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	for _, entry := range entries {
+		filetypes := FiletypesFor(entry.Name())
+		if entry.IsDir() || len(filetypes) == 0 || filetypes[0] != filetype {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		ycmdRequest.Filepath = path
+		ycmdRequest.FileContents = string(contents)
+		return ycmdRequest, nil
+	}
+	// Go, Rust and Java projects keep their sources below the directory with the project file.
+	pollRequest, ok := messagePollRequest(filetype)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no %s file in %s and no %s window open; open one of the project's files first",
+			filetype, dir, filetype))
+	}
+	ycmdRequest.Filepath = pollRequest.Filepath
+	ycmdRequest.FileContents = pollRequest.FileContents
+	return ycmdRequest, nil
+}
+
+// The request behind each +Symbols window, so refining the query from the results window asks the same completer.
+var symbolSearches = map[string]*YcmdRequest{}
+var symbolSearchesLock sync.Mutex
+
+func SymbolQuery(args []string) (string, error) {
+	query := strings.TrimSpace(strings.Join(args, " "))
+	if query == "" {
+		return "", errors.New("Sym needs a query: Sym <query>")
+	}
+	return query, nil
+}
+
+// Sym from a window without a source file: a directory, +IDE, or a +Symbols window being refined.
+func SearchProjectSymbols(ctx context.Context, winName string, args []string) error {
+	query, err := SymbolQuery(args)
+	if err != nil {
+		return err
+	}
+	symbolSearchesLock.Lock()
+	previous, ok := symbolSearches[ResultsWindowName(winName, "Symbols")]
+	symbolSearchesLock.Unlock()
+	var ycmdRequest *YcmdRequest
+	if ok {
+		copied := *previous
+		ycmdRequest = &copied
+	} else {
+		dir := filepath.Dir(winName)
+		ycmdRequest, err = NewProjectYcmdRequest(dir, ProjectFiletype(dir))
+		if err != nil {
+			return err
+		}
+	}
+	return RunSymbolSearch(ctx, winName, ycmdRequest, query)
+}
+
+// Sym from a source window searches the project with the window's completer. Without a query, the selection is used.
+func (p *PythonIde) SearchSymbols(ctx context.Context, args []string) error {
+	ycmdRequest, err := p.NewYcmdRequest()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		dotText, err := AcmeDotText(p.acmeWin, ycmdRequest.FileContents)
+		if err != nil {
+			return err
+		}
+		args = strings.Fields(dotText)
+	}
+	query, err := SymbolQuery(args)
+	if err != nil {
+		return err
+	}
+	return RunSymbolSearch(ctx, p.Name(), ycmdRequest, query)
+}
+
+func RunSymbolSearch(ctx context.Context, winName string, ycmdRequest *YcmdRequest, query string) error {
+	ycmdRequest.CommandArguments = []string{"GoToSymbol", query}
+	blob, err := PostHandler(ctx, "run_completer_command", ycmdRequest)
+	if err != nil {
+		return err
+	}
+	locations, err := DecodeFileLocations(blob)
+	if err != nil {
+		return err
+	}
+	resultsName := ResultsWindowName(winName, "Symbols")
+	symbolSearchesLock.Lock()
+	symbolSearches[resultsName] = ycmdRequest
+	symbolSearchesLock.Unlock()
+	return AcmeReplaceWindowBody(resultsName, FormatSymbols(query, locations))
+}
+
+// One symbol per line: its clickable address, then its kind, name and container.
+func FormatSymbols(query string, locations FileLocations) string {
+	sorted := make(FileLocations, len(locations))
+	copy(sorted, locations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Filepath != sorted[j].Filepath {
+			return sorted[i].Filepath < sorted[j].Filepath
+		}
+		return sorted[i].LineNum < sorted[j].LineNum
+	})
+	var b strings.Builder
+	fmt.Fprintf(&b, "Symbols matching %q: %d results\n\n", query, len(sorted))
+	for _, location := range sorted {
+		kind, name := ParseSymbolDescription(location.Description)
+		if extraKind := location.ExtraDataString("kind"); extraKind != "" {
+			kind = extraKind
+		}
+		if extraName := location.ExtraDataString("name"); extraName != "" {
+			name = extraName
+		}
+		if kind == "" {
+			kind = "symbol"
+		}
+		line := fmt.Sprintf("%s\t%s %s", location.String(), strings.ToLower(kind), name)
+		container := location.ExtraDataString("container_name")
+		if container == "" {
+			container = location.ExtraDataString("container")
+		}
+		if container != "" {
+			line = fmt.Sprintf("%s in %s", line, container)
+		}
+		fmt.Fprintln(&b, line)
+	}
+	return b.String()
+}
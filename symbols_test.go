@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatSymbols(t *testing.T) {
+	locations := FileLocations{
+		{LineNum: 20, ColumnNum: 5, Filepath: "/tmp/wtf.py", Description: "Method: render",
+			ExtraData: map[string]interface{}{"kind": "Method", "name": "render", "container_name": "IgorStyle"}},
+		{LineNum: 6, ColumnNum: 1, Filepath: "/tmp/wtf.py", Description: "Class: IgorStyle"},
+	}
+	expected := strings.Join([]string{
+		"Symbols matching \"r\": 2 results",
+		"",
+		"/tmp/wtf.py:6:1\tclass IgorStyle",
+		"/tmp/wtf.py:20:5\tmethod render in IgorStyle",
+		"",
+	}, "\n")
+	actual := FormatSymbols("r", locations)
+	if actual != expected {
+		t.Logf("Expected:\n%s\nbut received:\n%s\n", expected, actual)
+		t.Fail()
+	}
+}
+
+func TestProjectFiletype(t *testing.T) {
+	root, err := ioutil.TempDir("", "acmeide")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(root)
+	nested := filepath.Join(root, "src", "lib")
+	err = os.MkdirAll(nested, 0755)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(root, "compile_commands.json"), []byte("[]"), 0644)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if actual := ProjectFiletype(nested); actual != "cpp" {
+		t.Logf("Expected filetype cpp but received %s\n", actual)
+		t.Fail()
+	}
+}
+
+func TestNewProjectYcmdRequest(t *testing.T) {
+	root, err := ioutil.TempDir("", "acmeide")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(root)
+	err = ioutil.WriteFile(filepath.Join(root, "setup.py"), []byte("import setuptools\n"), 0644)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	ycmdRequest, err := NewProjectYcmdRequest(root, "python")
+	if err != nil || ycmdRequest.Filepath != filepath.Join(root, "setup.py") {
+		t.Logf("%+v %s", ycmdRequest, err)
+		t.Fail()
+	}
+	if _, err := NewProjectYcmdRequest(root, "rust"); err == nil {
+		t.Log("Without a rust file or window there's nothing to ask ycmd about")
+		t.Fail()
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Keywords and builtin names for each ycmd filetype, which Vim would take from its syntax files. Sent as
+// syntax_keywords so the identifier completer offers them before they appear in any buffer.
+var builtinSyntaxKeywords = map[string]string{
+	"python": `False None True and as assert async await break class continue def del elif else except finally for
+		from global if import in is lambda nonlocal not or pass raise return try while with yield
+		abs all any ascii bin bool breakpoint bytearray bytes callable chr classmethod compile complex delattr dict dir
+		divmod enumerate eval exec filter float format frozenset getattr globals hasattr hash help hex id input int
+		isinstance issubclass iter len list locals map max memoryview min next object oct open ord pow print property
+		range repr reversed round set setattr slice sorted staticmethod str sum super tuple type vars zip __import__
+		__init__ __name__ __main__ self cls Exception BaseException ValueError TypeError KeyError IndexError
+		AttributeError RuntimeError StopIteration NotImplementedError OSError ImportError`,
+	"c": `auto break case char const continue default do double else enum extern float for goto if inline int long
+		register restrict return short signed sizeof static struct switch typedef union unsigned void volatile while
+		_Alignas _Alignof _Atomic _Bool _Complex _Generic _Imaginary _Noreturn _Static_assert _Thread_local
+		NULL EOF FILE size_t ssize_t ptrdiff_t intptr_t uintptr_t int8_t int16_t int32_t int64_t uint8_t uint16_t
+		uint32_t uint64_t bool true false errno stdin stdout stderr`,
+	"cpp": `alignas alignof and and_eq asm auto bitand bitor bool break case catch char char8_t char16_t char32_t class
+		compl concept const consteval constexpr constinit const_cast continue co_await co_return co_yield decltype
+		default delete do double dynamic_cast else enum explicit export extern false float for friend goto if inline
+		int long mutable namespace new noexcept not not_eq nullptr operator or or_eq private protected public register
+		reinterpret_cast requires return short signed sizeof static static_assert static_cast struct switch template
+		this thread_local throw true try typedef typeid typename union unsigned using virtual void volatile wchar_t
+		while xor xor_eq override final
+		std string vector map unordered_map set unordered_set pair tuple optional variant unique_ptr shared_ptr
+		weak_ptr make_unique make_shared move forward size_t nullptr_t cout cerr endl`,
+	"go": `break case chan const continue default defer else fallthrough for func go goto if import interface map
+		package range return select struct switch type var
+		any bool byte comparable complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string
+		uint uint8 uint16 uint32 uint64 uintptr true false iota nil
+		append cap clear close complex copy delete imag len make max min new panic print println real recover`,
+	"rust": `as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod
+		move mut pub ref return self Self static struct super trait true type union unsafe use where while
+		bool char str i8 i16 i32 i64 i128 isize u8 u16 u32 u64 u128 usize f32 f64
+		Option Some None Result Ok Err Box Vec String Rc Arc RefCell Cell HashMap HashSet Default Clone Copy Debug
+		println print eprintln format vec panic assert assert_eq unreachable todo unimplemented`,
+	"javascript": `async await break case catch class const continue debugger default delete do else export extends
+		false finally for function if import in instanceof let new null of return static super switch this throw
+		true try typeof undefined var void while with yield
+		Array Boolean Date Error JSON Map Math Number Object Promise Proxy Reflect RegExp Set String Symbol WeakMap
+		WeakSet console document window globalThis parseInt parseFloat isNaN setTimeout setInterval clearTimeout
+		clearInterval require module exports`,
+	"java": `abstract assert boolean break byte case catch char class const continue default do double else enum
+		extends final finally float for goto if implements import instanceof int interface long native new package
+		private protected public return short static strictfp super switch synchronized this throw throws transient
+		try void volatile while var record sealed permits yield true false null
+		Object String Integer Long Double Float Boolean Character Byte Short Math System List ArrayList Map HashMap
+		Set HashSet Optional Exception RuntimeException Override`,
+}
+
+// Where the user's own keywords for a filetype live: one or more per line, with # starting a comment.
+func UserSyntaxKeywordsPath(filetype string) string {
+	configDir := ConfigDir()
+	if configDir == "" {
+		return ""
+	}
+	return filepath.Join(configDir, "syntax", filetype+".keywords")
+}
+
+func ParseSyntaxKeywords(contents string) []string {
+	var keywords []string
+	for _, line := range strings.Split(contents, "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		keywords = append(keywords, strings.Fields(line)...)
+	}
+	return keywords
+}
+
+// The built-in keywords for the filetype followed by the user's, without duplicates. The user's file is read each
+// time, so edits apply to the next parse.
+func SyntaxKeywordsFor(filetype string) []string {
+	keywords := ParseSyntaxKeywords(builtinSyntaxKeywords[filetype])
+	if path := UserSyntaxKeywordsPath(filetype); path != "" {
+		blob, err := ioutil.ReadFile(path)
+		if err == nil {
+			keywords = append(keywords, ParseSyntaxKeywords(string(blob))...)
+		} else if !os.IsNotExist(err) {
+			log.Printf("Error reading %s: %s\n", path, err)
+		}
+	}
+	seen := make(map[string]bool, len(keywords))
+	unique := keywords[:0]
+	for _, keyword := range keywords {
+		if !seen[keyword] {
+			seen[keyword] = true
+			unique = append(unique, keyword)
+		}
+	}
+	return unique
+}
+
+// Whether ycmd reads syntax_keywords. It only does on the events that seed the identifier completer.
+func SendsSyntaxKeywords(eventName string) bool {
+	return (eventName == "FileReadyToParse" || eventName == "BufferVisit") && SeedsIdentifiersWithSyntax()
+}
+
+// Tell ycmd a window was opened, so the identifier completer is seeded before the first parse.
+func (p *PythonIde) VisitBuffer(ctx context.Context) error {
+	_, err := p.SendEventNotification(ctx, "BufferVisit")
+	if err != nil {
+		log.Printf("BufferVisit for %s: %s\n", p.Name(), err)
+	}
+	return nil
+}
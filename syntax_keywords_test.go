@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSyntaxKeywords(t *testing.T) {
+	contents := "# project types\nWidget Gadget  # the main ones\n\n\tmake_widget\n"
+	expected := []string{"Widget", "Gadget", "make_widget"}
+	if actual := ParseSyntaxKeywords(contents); !reflect.DeepEqual(actual, expected) {
+		t.Logf("%+v", actual)
+		t.Fail()
+	}
+}
+
+func TestSyntaxKeywordsFor(t *testing.T) {
+	for _, filetype := range []string{"python", "c", "cpp", "go", "rust", "javascript", "java"} {
+		keywords := SyntaxKeywordsFor(filetype)
+		if len(keywords) == 0 {
+			t.Logf("No keywords for %s", filetype)
+			t.Fail()
+		}
+		seen := map[string]bool{}
+		for _, keyword := range keywords {
+			if seen[keyword] {
+				t.Logf("%s: %s twice", filetype, keyword)
+				t.Fail()
+			}
+			seen[keyword] = true
+		}
+	}
+	if len(SyntaxKeywordsFor("cobol")) != 0 {
+		t.Fail()
+	}
+}
+
+func TestSyntaxKeywordsReachEverySourceWindow(t *testing.T) {
+	names := map[string]string{
+		"python":     "/src/a.py",
+		"c":          "/src/a.c",
+		"cpp":        "/src/a.cpp",
+		"go":         "/src/a.go",
+		"rust":       "/src/a.rs",
+		"javascript": "/src/a.js",
+		"java":       "/src/A.java",
+	}
+	for filetype := range builtinSyntaxKeywords {
+		ide, ok := NewIde(3201, names[filetype]).(*PythonIde)
+		if !ok {
+			t.Logf("%s windows don't send syntax keywords", filetype)
+			t.Fail()
+			continue
+		}
+		if filetypes := ide.Filetypes(); len(filetypes) == 0 || filetypes[0] != filetype {
+			t.Logf("%s: %+v", names[filetype], filetypes)
+			t.Fail()
+		}
+	}
+}
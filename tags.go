@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The tag file names looked for when tag_files isn't set.
+var DefaultTagFileNames = []string{"tags", "TAGS"}
+
+func tagFileNames() []string {
+	settings := GetIdeSettings()
+	if settings == nil || settings.TagFiles == nil {
+		return DefaultTagFileNames
+	}
+	return settings.TagFiles
+}
+
+func isRegularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// The tag files for a file in dir: each of names found in dir or any directory above it, nearest first. Absolute names
+// are used as they are, if they exist.
+func FindTagFiles(dir string, names []string, exists func(path string) bool) []string {
+	var found []string
+	seen := map[string]bool{}
+	add := func(path string) {
+		if !seen[path] && exists(path) {
+			seen[path] = true
+			found = append(found, path)
+		}
+	}
+	for {
+		for _, name := range names {
+			if !filepath.IsAbs(name) {
+				add(filepath.Join(dir, name))
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	for _, name := range names {
+		if filepath.IsAbs(name) {
+			add(name)
+		}
+	}
+	return found
+}
+
+// The tag files ycmd should read identifiers from for the window.
+func (p *PythonIde) TagFiles() []string {
+	return FindTagFiles(filepath.Dir(p.Name()), tagFileNames(), isRegularFile)
+}
+
+func FormatTagFiles(path string, tagFiles []string, collecting bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tag files for %s: %d\n", path, len(tagFiles))
+	if !collecting {
+		fmt.Fprintf(&b, "collect_identifiers_from_tags_files is off, so they aren't sent to ycmd\n")
+	}
+	fmt.Fprintf(&b, "\n")
+	for _, tagFile := range tagFiles {
+		fmt.Fprintf(&b, "%s\n", tagFile)
+	}
+	return b.String()
+}
+
+// Tags shows the tag files found for the window in +Tags, which go with its event notifications when
+// collect_identifiers_from_tags_files is on.
+func (p *PythonIde) ShowTagFiles(ctx context.Context) error {
+	contents := FormatTagFiles(p.Name(), p.TagFiles(), CollectsIdentifiersFromTagsFiles())
+	return AcmeReplaceWindowBody(ResultsWindowName(p.Name(), "Tags"), contents)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindTagFiles(t *testing.T) {
+	existing := map[string]bool{
+		"/src/project/lib/tags": true,
+		"/src/project/TAGS":     true,
+		"/src/tags":             true,
+		"/usr/share/sys.tags":   true,
+	}
+	exists := func(path string) bool { return existing[path] }
+	names := []string{"tags", "TAGS", "/usr/share/sys.tags", "/missing/tags"}
+	expected := []string{"/src/project/lib/tags", "/src/project/TAGS", "/src/tags", "/usr/share/sys.tags"}
+	if actual := FindTagFiles("/src/project/lib", names, exists); !reflect.DeepEqual(actual, expected) {
+		t.Logf("%+v", actual)
+		t.Fail()
+	}
+	if actual := FindTagFiles("/elsewhere", []string{"tags"}, exists); len(actual) != 0 {
+		t.Logf("%+v", actual)
+		t.Fail()
+	}
+}
+
+func TestFormatTagFiles(t *testing.T) {
+	expected := "Tag files for /src/a.c: 1\n" +
+		"collect_identifiers_from_tags_files is off, so they aren't sent to ycmd\n\n" +
+		"/src/tags\n"
+	if actual := FormatTagFiles("/src/a.c", []string{"/src/tags"}, false); actual != expected {
+		t.Log(actual)
+		t.Fail()
+	}
+}
+
+func TestEventNotificationTagFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "acmeide")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(root)
+	err = ioutil.WriteFile(filepath.Join(root, "tags"), []byte("main\tmain.c\t/^int main(/\n"), 0644)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer UpdateCurrentSettings(DefaultSettings())
+	ide := NewPythonIde(3101, filepath.Join(root, "main.c"))
+	settings := DefaultSettings()
+	UpdateCurrentSettings(settings)
+	if _, ok := ide.EventNotificationFields("FileReadyToParse")["tag_files"]; ok {
+		t.Log("tag_files shouldn't be sent while collect_identifiers_from_tags_files is off")
+		t.Fail()
+	}
+	settings = DefaultSettings()
+	settings.CollectIdentifiersFromTagsFiles = 1
+	UpdateCurrentSettings(settings)
+	expected := []string{filepath.Join(root, "tags")}
+	if actual := ide.EventNotificationFields("FileReadyToParse")["tag_files"]; !reflect.DeepEqual(actual, expected) {
+		t.Logf("%+v", actual)
+		t.Fail()
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"9fans.net/go/acme"
+)
+
+// A node in a tree of locations, like a call or type hierarchy.
+type TreeNode struct {
+	Location FileLocation
+	Label    string
+	Expanded bool
+	Children []*TreeNode
+	// Whatever the view needs to expand the node later.
+	Data interface{}
+}
+
+type TreeRow struct {
+	Node  *TreeNode
+	Depth int
+}
+
+// The visible nodes under n, n included, in display order.
+func (n *TreeNode) Rows(depth int) []TreeRow {
+	rows := []TreeRow{{Node: n, Depth: depth}}
+	if n.Expanded {
+		for _, child := range n.Children {
+			rows = append(rows, child.Rows(depth+1)...)
+		}
+	}
+	return rows
+}
+
+// A tree shown in a window. Expand fills in the children of a node, the first time it's opened. If Activate is set,
+// button 2 calls it instead of opening and closing nodes.
+type TreeView struct {
+	Name     string
+	Title    string
+	Root     *TreeNode
+	Expand   func(ctx context.Context, view *TreeView, node *TreeNode) error
+	Activate func(ctx context.Context, view *TreeView, node *TreeNode) error
+	rows     []TreeRow
+}
+
+// Lines before the first row: the title and a blank line.
+const treeHeaderLines = 2
+
+// One row per line, indented by depth and marked "+" if it can be opened or "-" if it is open, followed by the
+// clickable address.
+func FormatTree(title string, rows []TreeRow) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", title)
+	for _, row := range rows {
+		marker := "+"
+		if row.Node.Expanded {
+			marker = "-"
+		}
+		fmt.Fprintf(&b, "%s%s %s\t%s\n", strings.Repeat("    ", row.Depth), marker, row.Node.Label,
+			row.Node.Location.String())
+	}
+	return b.String()
+}
+
+var treeViews = map[string]*TreeView{}
+var treeViewsLock sync.Mutex
+
+// Show the view in its window, replacing whatever tree was there before, with dot on the given row.
+func ShowTree(view *TreeView, row int) error {
+	treeViewsLock.Lock()
+	treeViews[view.Name] = view
+	view.rows = view.Root.Rows(0)
+	contents := FormatTree(view.Title, view.rows)
+	treeViewsLock.Unlock()
+	return AcmeReplaceWindowBodyAt(view.Name, contents, fmt.Sprintf("%d", row+treeHeaderLines+1))
+}
+
+// The row at a rune offset in the window body, or -1 for the title.
+func treeRowAt(body []rune, q int) int {
+	line, _ := LineAndColumnOfOffset(body, q)
+	return line - treeHeaderLines - 1
+}
+
+func ToggleTreeNode(ctx context.Context, view *TreeView, row int) error {
+	treeViewsLock.Lock()
+	if row < 0 || row >= len(view.rows) {
+		treeViewsLock.Unlock()
+		return nil
+	}
+	node := view.rows[row].Node
+	treeViewsLock.Unlock()
+	if node.Expanded {
+		node.Expanded = false
+	} else {
+		if node.Children == nil {
+			err := view.Expand(ctx, view, node)
+			if err != nil {
+				return err
+			}
+		}
+		node.Expanded = true
+	}
+	return ShowTree(view, row)
+}
+
+// In a tree window button 2 opens or closes a node (or activates it), and button 3 jumps to it using the navigation policy named after
+// the window kind, e.g. "Calls". Returns false if the event isn't for a tree window and should go back to acme.
+func HandleTreeClick(ide Ide, win *acme.Win, e *acme.Event) (*IdeJob, error) {
+	area, err := WhichAcmeArea(e)
+	if err != nil || area != AcmeAreaBody {
+		return nil, nil
+	}
+	button, err := WhichAcmeButton(e)
+	if err != nil {
+		return nil, nil
+	}
+	winName, err := AcmeWinName(win)
+	if err != nil {
+		return nil, nil
+	}
+	treeViewsLock.Lock()
+	view, ok := treeViews[winName]
+	treeViewsLock.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	body, err := win.ReadAll("body")
+	if err != nil {
+		return nil, err
+	}
+	row := treeRowAt([]rune(string(body)), e.OrigQ0)
+	treeViewsLock.Lock()
+	var node *TreeNode
+	if row >= 0 && row < len(view.rows) {
+		node = view.rows[row].Node
+	}
+	treeViewsLock.Unlock()
+	if node == nil {
+		// Let acme deal with clicks on the title.
+		return nil, nil
+	}
+	if button == AcmeButtonTwo && view.Activate != nil {
+		return &IdeJob{Name: "Activate", Run: func(ctx context.Context) error {
+			return view.Activate(ctx, view, node)
+		}}, nil
+	}
+	if button == AcmeButtonTwo {
+		return &IdeJob{Name: "Expand", Run: func(ctx context.Context) error {
+			return ToggleTreeNode(ctx, view, row)
+		}}, nil
+	}
+	if _, err := os.Stat(node.Location.Filepath); err != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", node.Location.String(), err))
+	}
+	kind := strings.TrimPrefix(winName[strings.LastIndex(winName, "+"):], "+")
+	policy := CurrentNavigationPolicy(kind, button)
+	if policy == NavigateAuto {
+		// Opening in place would replace the tree we just clicked in.
+		policy = NavigateReuseWindow
+	}
+	location := node.Location
+	return NewJumpJob(ide, win, &location, policy), nil
+}
+
+// A request positioned at location, with the contents acme or the disk has for it.
+func NewLocationYcmdRequest(location FileLocation, commandArguments ...string) (*YcmdRequest, error) {
+	lines, err := SourceLines(location.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	filetypes := FiletypesFor(location.Filepath)
+	if len(filetypes) == 0 {
+		filetypes = []string{ProjectFiletype(location.Filepath)}
+	}
+	return &YcmdRequest{
+		LineNum:          location.LineNum,
+		ColumnNum:        location.ColumnNum,
+		Filepath:         location.Filepath,
+		FileContents:     strings.Join(lines, "\n"),
+		Filetypes:        filetypes,
+		CommandArguments: commandArguments,
+		OtherFileData:    DirtyBufferFileData(location.Filepath),
+	}, nil
+}
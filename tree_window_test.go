@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatTree(t *testing.T) {
+	root := &TreeNode{
+		Location: FileLocation{LineNum: 20, ColumnNum: 5, Filepath: "/tmp/wtf.py"},
+		Label:    "def render(self):",
+		Expanded: true,
+		Children: []*TreeNode{
+			{Location: FileLocation{LineNum: 3, ColumnNum: 9, Filepath: "/tmp/a.py"}, Label: "style.render()"},
+			{Location: FileLocation{LineNum: 7, ColumnNum: 1, Filepath: "/tmp/b.py"}, Label: "render()",
+				Children: []*TreeNode{{Label: "hidden"}}},
+		},
+	}
+	rows := root.Rows(0)
+	if len(rows) != 3 {
+		t.Logf("Expected 3 visible rows but received %d\n", len(rows))
+		t.FailNow()
+	}
+	expected := strings.Join([]string{
+		"GoToCallers of /tmp/wtf.py:20:5",
+		"",
+		"- def render(self):\t/tmp/wtf.py:20:5",
+		"    + style.render()\t/tmp/a.py:3:9",
+		"    + render()\t/tmp/b.py:7:1",
+		"",
+	}, "\n")
+	actual := FormatTree("GoToCallers of /tmp/wtf.py:20:5", rows)
+	if actual != expected {
+		t.Logf("Expected:\n%s\nbut received:\n%s\n", expected, actual)
+		t.Fail()
+	}
+	body := []rune(actual)
+	if row := treeRowAt(body, strings.Index(actual, "style")); row != 1 {
+		t.Logf("Expected row 1 but received %d\n", row)
+		t.Fail()
+	}
+}
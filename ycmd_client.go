@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ycmd only listens on IPv4 loopback. Asking for localhost can resolve to ::1 first and fail or wait.
+const YcmdHost = "127.0.0.1"
+
+const YcmdDialTimeout = 2 * time.Second
+
+// One client per ycmd instance, so connections are kept alive and reused between requests.
+var ycmdClient *http.Client
+var ycmdClientLock sync.Mutex
+
+func NewYcmdTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   YcmdDialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		// Never send requests to a local server through a proxy from the environment.
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        16,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+		DisableCompression:  true,
+	}
+}
+
+// Start using a fresh client, e.g. because ycmd was (re)started on a new port. Connections to the old one are closed.
+func ResetYcmdClient() {
+	ycmdClientLock.Lock()
+	defer ycmdClientLock.Unlock()
+	if ycmdClient != nil {
+		ycmdClient.CloseIdleConnections()
+	}
+	ycmdClient = &http.Client{Transport: NewYcmdTransport()}
+}
+
+func GetYcmdClient() *http.Client {
+	ycmdClientLock.Lock()
+	defer ycmdClientLock.Unlock()
+	if ycmdClient == nil {
+		ycmdClient = &http.Client{Transport: NewYcmdTransport()}
+	}
+	return ycmdClient
+}
+
+func YcmdUrl(handler string) string {
+	return fmt.Sprintf("http://%s/%s", net.JoinHostPort(YcmdHost, GetCurrentPort()), handler)
+}
+
+// Round trip times for one kind of request.
+type Latency struct {
+	Count int
+	Total time.Duration
+	Max   time.Duration
+	Last  time.Duration
+}
+
+func (l *Latency) Mean() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+	return l.Total / time.Duration(l.Count)
+}
+
+func (l *Latency) Record(d time.Duration) {
+	l.Count++
+	l.Total += d
+	l.Last = d
+	if d > l.Max {
+		l.Max = d
+	}
+}
+
+// Latencies by request kind, keyed like request_timeouts, e.g. "run_completer_command:GoTo".
+var latencies = map[string]*Latency{}
+var latenciesLock sync.Mutex
+
+func RecordLatency(key string, d time.Duration) {
+	latenciesLock.Lock()
+	defer latenciesLock.Unlock()
+	latency, ok := latencies[key]
+	if !ok {
+		latency = &Latency{}
+		latencies[key] = latency
+	}
+	latency.Record(d)
+	log.Printf("%s took %s\n", key, d)
+}
+
+func FormatLatencies(latencies map[string]*Latency) string {
+	keys := make([]string, 0, len(latencies))
+	width := len("request")
+	for key := range latencies {
+		keys = append(keys, key)
+		if len(key) > width {
+			width = len(key)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	fmt.Fprintf(&b, "ycmd round trips since start\n\n")
+	fmt.Fprintf(&b, "%-*s %6s %9s %9s %9s\n", width, "request", "count", "mean", "max", "last")
+	for _, key := range keys {
+		l := latencies[key]
+		fmt.Fprintf(&b, "%-*s %6d %9s %9s %9s\n", width, key, l.Count,
+			l.Mean().Round(time.Millisecond), l.Max.Round(time.Millisecond), l.Last.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// Show the latencies in the +Stats window next to the window the command came from.
+func ShowLatencies(winName string) error {
+	latenciesLock.Lock()
+	contents := FormatLatencies(latencies)
+	latenciesLock.Unlock()
+	return AcmeReplaceWindowBody(ResultsWindowName(winName, "Stats"), contents)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestYcmdUrl(t *testing.T) {
+	port := GetCurrentPort()
+	defer SetCurrentPort(port)
+	SetCurrentPort("4321")
+	if url := YcmdUrl("ready"); url != "http://127.0.0.1:4321/ready" {
+		t.Log(url)
+		t.Fail()
+	}
+}
+
+// Requests one after another should share a single connection.
+func TestGetYcmdClient_KeepAlive(t *testing.T) {
+	var newConnections int
+	var lock sync.Mutex
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("true"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			lock.Lock()
+			newConnections++
+			lock.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+	ResetYcmdClient()
+	for i := 0; i < 3; i++ {
+		resp, err := GetYcmdClient().Get(server.URL)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if newConnections != 1 {
+		t.Logf("%d connections for 3 requests", newConnections)
+		t.Fail()
+	}
+}
+
+func TestFormatLatencies(t *testing.T) {
+	goTo := &Latency{}
+	goTo.Record(30 * time.Millisecond)
+	goTo.Record(10 * time.Millisecond)
+	ready := &Latency{}
+	ready.Record(time.Millisecond)
+	formatted := FormatLatencies(map[string]*Latency{"run_completer_command:GoTo": goTo, "ready": ready})
+	lines := strings.Split(formatted, "\n")
+	if len(lines) != 6 {
+		t.Log(formatted)
+		t.FailNow()
+	}
+	if strings.Join(strings.Fields(lines[3]), " ") != "ready 1 1ms 1ms 1ms" {
+		t.Logf("%q", lines[3])
+		t.Fail()
+	}
+	if strings.Join(strings.Fields(lines[4]), " ") != "run_completer_command:GoTo 2 20ms 30ms 10ms" {
+		t.Logf("%q", lines[4])
+		t.Fail()
+	}
+}
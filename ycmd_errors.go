@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// An error response from ycmd. ycmd reports exceptions as JSON with the exception, its message and a traceback.
+type YcmdError struct {
+	Handler    string
+	StatusCode int
+	Exception  map[string]interface{} `json:"exception"`
+	Message    string                 `json:"message"`
+	Traceback  string                 `json:"traceback"`
+}
+
+func NewYcmdError(handler string, statusCode int, blob []byte) *YcmdError {
+	ycmdError := &YcmdError{}
+	err := json.Unmarshal(blob, ycmdError)
+	if err != nil || (ycmdError.Message == "" && ycmdError.Exception == nil) {
+		ycmdError.Message = strings.TrimSpace(string(blob))
+		if ycmdError.Message == "" {
+			ycmdError.Message = http.StatusText(statusCode)
+		}
+	}
+	ycmdError.Handler = handler
+	ycmdError.StatusCode = statusCode
+	return ycmdError
+}
+
+// The exception class, e.g. RuntimeError or UnknownExtraConf.
+func (e *YcmdError) Type() string {
+	if exceptionType, ok := e.Exception["TYPE"].(string); ok {
+		return exceptionType
+	}
+	return ""
+}
+
+func (e *YcmdError) ExceptionString(key string) string {
+	if value, ok := e.Exception[key].(string); ok {
+		return value
+	}
+	return ""
+}
+
+// What to tell the user about the common ycmd errors. The first entry that matches wins.
+var ycmdErrorExplanations = []struct {
+	Type    string
+	Message string
+	Explain func(e *YcmdError) string
+}{
+	{Type: "UnknownExtraConf", Explain: func(e *YcmdError) string {
+		return fmt.Sprintf("%s has to be confirmed before ycmd will load it. "+
+			"Click Load or Ignore in +Errors, or add it to extra_conf_globlist.", e.ExceptionString("extra_conf_file"))
+	}},
+	{Message: "Can't jump to definition", Explain: func(e *YcmdError) string {
+		return "No definition found for the symbol at dot."
+	}},
+	{Message: "Can't jump to declaration", Explain: func(e *YcmdError) string {
+		return "No declaration found for the symbol at dot."
+	}},
+	{Message: "Cannot jump to location", Explain: func(e *YcmdError) string {
+		return "Nothing to jump to from dot."
+	}},
+	{Message: "No semantic completer exists", Explain: func(e *YcmdError) string {
+		return "ycmd has no semantic completer for this filetype. Is it installed and enabled?"
+	}},
+	{Message: "Still no compile flags", Explain: func(e *YcmdError) string {
+		return "No compile flags for this file. Add a compile_commands.json or .ycm_extra_conf.py."
+	}},
+	{Message: "No compilation database", Explain: func(e *YcmdError) string {
+		return "No compile flags for this file. Add a compile_commands.json or .ycm_extra_conf.py."
+	}},
+	{Message: "Server is initializing. Please wait.", Explain: func(e *YcmdError) string {
+		return "The language server is still starting. Try again shortly."
+	}},
+}
+
+// A short explanation of the error, for the user.
+func (e *YcmdError) Explanation() string {
+	if e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden {
+		return "ycmd rejected the request. Is the HMAC secret right?"
+	}
+	for _, explanation := range ycmdErrorExplanations {
+		if explanation.Type != "" && explanation.Type != e.Type() {
+			continue
+		}
+		if explanation.Message != "" && !strings.Contains(e.Message, explanation.Message) {
+			continue
+		}
+		return explanation.Explain(e)
+	}
+	if e.Type() != "" {
+		return fmt.Sprintf("%s: %s", e.Type(), e.Message)
+	}
+	return e.Message
+}
+
+func (e *YcmdError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Handler, e.Explanation())
+}
+
+// Tell the user about an error in the +Errors window next to the window it happened in.
+func ReportError(ide Ide, err error) {
+	log.Printf("%s: %s\n", ide.Name(), err)
+	if ycmdError, ok := err.(*YcmdError); ok && ycmdError.Traceback != "" {
+		log.Printf("ycmd traceback: %s\n", ycmdError.Traceback)
+	}
+	writeErr := AcmeWriteToErrors(ide.Id(), ide.Name(), fmt.Sprintf("%s\n", err))
+	if writeErr != nil {
+		log.Printf("Error writing Errors: %s\n", writeErr)
+	}
+}
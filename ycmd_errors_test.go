@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewYcmdError(t *testing.T) {
+	blob := []byte(`{"exception": {"TYPE": "RuntimeError"}, "message": "Can't jump to definition.", "traceback": "Traceback..."}`)
+	ycmdError := NewYcmdError("run_completer_command", 500, blob)
+	if ycmdError.Type() != "RuntimeError" {
+		t.Logf("Type: %q", ycmdError.Type())
+		t.Fail()
+	}
+	if ycmdError.Traceback != "Traceback..." {
+		t.Logf("Traceback: %q", ycmdError.Traceback)
+		t.Fail()
+	}
+	if ycmdError.Error() != "run_completer_command: No definition found for the symbol at dot." {
+		t.Logf("Error: %q", ycmdError.Error())
+		t.Fail()
+	}
+	if strings.Contains(ycmdError.Error(), "Traceback") {
+		t.Log("The traceback should only be logged")
+		t.Fail()
+	}
+}
+
+func TestNewYcmdError_NotJson(t *testing.T) {
+	ycmdError := NewYcmdError("ready", 502, []byte("Bad Gateway\n"))
+	if ycmdError.Error() != "ready: Bad Gateway" {
+		t.Logf("Error: %q", ycmdError.Error())
+		t.Fail()
+	}
+	ycmdError = NewYcmdError("ready", 503, nil)
+	if ycmdError.Message != "Service Unavailable" {
+		t.Logf("Message: %q", ycmdError.Message)
+		t.Fail()
+	}
+}
+
+func TestYcmdError_Explanation(t *testing.T) {
+	cases := []struct {
+		StatusCode int
+		Blob       string
+		Contains   string
+	}{
+		{500, `{"exception": {"TYPE": "UnknownExtraConf", "extra_conf_file": "/src/.ycm_extra_conf.py"}, "message": "Found /src/.ycm_extra_conf.py. Load?"}`, "/src/.ycm_extra_conf.py has to be confirmed"},
+		{500, `{"exception": {"TYPE": "ValueError"}, "message": "No semantic completer exists for filetypes: ['text']"}`, "no semantic completer"},
+		{500, `{"exception": {"TYPE": "RuntimeError"}, "message": "Still no compile flags, no completions yet."}`, "compile_commands.json"},
+		{500, `{"exception": {"TYPE": "RuntimeError"}, "message": "Server is initializing. Please wait."}`, "still starting"},
+		{500, `{"exception": {"TYPE": "RuntimeError"}, "message": "Failed initializing the index"}`, "RuntimeError: Failed initializing"},
+		{401, `{"message": "Unauthorized"}`, "HMAC"},
+		{500, `{"exception": {"TYPE": "KeyError"}, "message": "'filepath'"}`, "KeyError: 'filepath'"},
+	}
+	for _, c := range cases {
+		explanation := NewYcmdError("run_completer_command", c.StatusCode, []byte(c.Blob)).Explanation()
+		if !strings.Contains(explanation, c.Contains) {
+			t.Logf("Expected %q in %q", c.Contains, explanation)
+			t.Fail()
+		}
+	}
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"strings"
-	"errors"
 )
 
 type YcmdRequest struct {
@@ -15,19 +17,46 @@ type YcmdRequest struct {
 	Filetypes        []string
 	CommandArguments []string
 	CompleterTarget  string
+	// Only sent for requests that work on a range, such as inlay hints.
+	Range *YcmdRange
+	// Handler specific fields, sent as is.
+	ExtraFields map[string]interface{}
+	// Unsaved contents of other open files, so ycmd doesn't parse them from disk.
+	OtherFileData map[string]YcmdFileData
+}
+
+type YcmdFileData struct {
+	Filetypes []string `json:"filetypes"`
+	Contents  string   `json:"contents"`
+}
+
+type YcmdPosition struct {
+	LineNum   int `json:"line_num"`
+	ColumnNum int `json:"column_num"`
+}
+
+type YcmdRange struct {
+	Start YcmdPosition `json:"start"`
+	End   YcmdPosition `json:"end"`
 }
 
 func (r *YcmdRequest) MarshalJSON() ([]byte, error) {
+	fileData := map[string]interface{}{
+		r.Filepath: map[string]interface{}{
+			"filetypes": r.Filetypes,
+			"contents":  r.FileContents,
+		},
+	}
+	for path, data := range r.OtherFileData {
+		if path != r.Filepath {
+			fileData[path] = data
+		}
+	}
 	blob := map[string]interface{}{
 		"line_num":   r.LineNum,
 		"column_num": r.ColumnNum,
 		"filepath":   r.Filepath,
-		"file_data": map[string]interface{}{
-			r.Filepath: map[string]interface{}{
-				"filetypes": r.Filetypes,
-				"contents":  r.FileContents,
-			},
-		},
+		"file_data":  fileData,
 	}
 	if r.CommandArguments != nil {
 		blob["command_arguments"] = r.CommandArguments
@@ -35,6 +64,12 @@ func (r *YcmdRequest) MarshalJSON() ([]byte, error) {
 	if r.CompleterTarget != "" {
 		blob["completer_target"] = r.CompleterTarget
 	}
+	if r.Range != nil {
+		blob["range"] = r.Range
+	}
+	for key, value := range r.ExtraFields {
+		blob[key] = value
+	}
 	return json.Marshal(blob)
 }
 
@@ -69,6 +104,30 @@ func NewRawPlumberLocation(location string) (*RawPlumberLocation, error) {
 	return &RawPlumberLocation{Filepath:location[:sepIdx], Address:location[sepIdx+1:]}, nil
 }
 
+// Parse a location as printed in results windows: "file:line:col", or "file:addr" for anything acme can address.
+// Relative paths are resolved against dir.
+func ParseLocation(text, dir string) (Location, error) {
+	rawLocation, err := NewRawPlumberLocation(strings.TrimSpace(text))
+	if err != nil {
+		return nil, err
+	}
+	if rawLocation.Filepath == "" {
+		return nil, errors.New("location has no file")
+	}
+	if !filepath.IsAbs(rawLocation.Filepath) && dir != "" {
+		rawLocation.Filepath = filepath.Join(dir, rawLocation.Filepath)
+	}
+	parts := strings.Split(rawLocation.Address, ":")
+	if len(parts) == 2 {
+		lineNum, lineErr := strconv.Atoi(parts[0])
+		columnNum, columnErr := strconv.Atoi(parts[1])
+		if lineErr == nil && columnErr == nil {
+			return &FileLocation{LineNum: lineNum, ColumnNum: columnNum, Filepath: rawLocation.Filepath}, nil
+		}
+	}
+	return rawLocation, nil
+}
+
 type DotLocation struct {
 	Q0 int
 	Q1 int
@@ -100,6 +159,15 @@ type FileLocation struct {
 	ColumnNum   int    `json:"column_num"`
 	Filepath    string `json:"filepath"`
 	Description string `json:"description"`
+	// Completer specific details, e.g. the symbol kind and name for GoToSymbol.
+	ExtraData map[string]interface{} `json:"extra_data,omitempty"`
+}
+
+func (y *FileLocation) ExtraDataString(key string) string {
+	if value, ok := y.ExtraData[key].(string); ok {
+		return value
+	}
+	return ""
 }
 
 func (y *FileLocation) Path() string {
@@ -114,7 +182,7 @@ func (y *FileLocation) DescriptionString() string {
 	if len(y.Description) > 0 {
 		return fmt.Sprintf("%s:%d:%s%s", y.Filepath, y.LineNum, strings.Repeat(" ", y.ColumnNum), y.Description)
 	} else {
-		return fmt.Sprintf("%s:%s", y.Filepath, y.LineNum)
+		return fmt.Sprintf("%s:%d", y.Filepath, y.LineNum)
 	}
 }
 
@@ -124,6 +192,21 @@ func (y *FileLocation) Addr() string {
 
 type FileLocations []FileLocation
 
+// Decode a ycmd response that is either a single location or a list of them.
+func DecodeFileLocations(blob []byte) (FileLocations, error) {
+	fileLocation := FileLocation{}
+	err := json.Unmarshal(blob, &fileLocation)
+	if err == nil {
+		return FileLocations{fileLocation}, nil
+	}
+	fileLocations := FileLocations{}
+	err = json.Unmarshal(blob, &fileLocations)
+	if err != nil {
+		return nil, err
+	}
+	return fileLocations, nil
+}
+
 func (ym FileLocations) String() string {
 	options := make([]string, 0, len(ym))
 	for _, y := range ym {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -63,4 +64,101 @@ func TestNewRawPlumberLocation(t *testing.T) {
 		t.Logf("Address: %s, expected %s\n", rl.Address, "26")
 		t.Fail()
 	}
-}
\ No newline at end of file
+}
+
+func TestParseLocation(t *testing.T) {
+	location, err := ParseLocation("samples/some_python.py:26:5", "/Users/elliot/src/ycmd/examples")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	fileLocation, ok := location.(*FileLocation)
+	if !ok {
+		t.Logf("Expected a FileLocation but received %T\n", location)
+		t.FailNow()
+	}
+	if fileLocation.Filepath != "/Users/elliot/src/ycmd/examples/samples/some_python.py" {
+		t.Logf("Filepath: %s, expected %s\n", fileLocation.Filepath, "/Users/elliot/src/ycmd/examples/samples/some_python.py")
+		t.Fail()
+	}
+	if fileLocation.LineNum != 26 || fileLocation.ColumnNum != 5 {
+		t.Logf("Line and column: %d:%d, expected 26:5\n", fileLocation.LineNum, fileLocation.ColumnNum)
+		t.Fail()
+	}
+	location, err = ParseLocation("/tmp/wtf.py:/IgorStyle/", "")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if location.Addr() != "/IgorStyle/" {
+		t.Logf("Addr: %s, expected %s\n", location.Addr(), "/IgorStyle/")
+		t.Fail()
+	}
+}
+
+func TestYcmdRequest_MarshalJSONRange(t *testing.T) {
+	ycmdRequest := &YcmdRequest{
+		LineNum:      2,
+		ColumnNum:    1,
+		Filepath:     "/tmp/wtf.py",
+		FileContents: "",
+		Filetypes:    []string{"python"},
+		Range: &YcmdRange{
+			Start: YcmdPosition{LineNum: 2, ColumnNum: 1},
+			End:   YcmdPosition{LineNum: 4, ColumnNum: 7},
+		},
+	}
+	blob, err := json.Marshal(ycmdRequest)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if !strings.Contains(string(blob), `"range":{"start":{"line_num":2,"column_num":1},"end":{"line_num":4,"column_num":7}}`) {
+		t.Logf("Range missing from %s\n", string(blob))
+		t.Fail()
+	}
+}
+
+func TestLineAndColumnOfRuneOffset(t *testing.T) {
+	// ycmd counts columns in bytes, so the « before x counts twice.
+	lineAndColumn := LineAndColumnOfRuneOffset([]rune("a\n«x» = 1\n"), 3)
+	if lineAndColumn.Line != 2 || lineAndColumn.Column != 3 {
+		t.Logf("Expected 2:3 but received %d:%d\n", lineAndColumn.Line, lineAndColumn.Column)
+		t.Fail()
+	}
+}
+
+func TestYcmdRequest_MarshalJSONOtherFileData(t *testing.T) {
+	ycmdRequest := &YcmdRequest{
+		LineNum:      1,
+		ColumnNum:    1,
+		Filepath:     "/src/a.cpp",
+		FileContents: "#include \"a.h\"\n",
+		Filetypes:    []string{"cpp"},
+		OtherFileData: map[string]YcmdFileData{
+			"/src/a.h":   {Filetypes: []string{"cpp"}, Contents: "int f();\n"},
+			"/src/a.cpp": {Filetypes: []string{"cpp"}, Contents: "stale"},
+		},
+	}
+	blob, err := json.Marshal(ycmdRequest)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	var decoded struct {
+		FileData map[string]YcmdFileData `json:"file_data"`
+	}
+	err = json.Unmarshal(blob, &decoded)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if decoded.FileData["/src/a.h"].Contents != "int f();\n" {
+		t.Log(string(blob))
+		t.Fail()
+	}
+	if decoded.FileData["/src/a.cpp"].Contents != "#include \"a.h\"\n" {
+		t.Log("The request's own file should win over OtherFileData")
+		t.Fail()
+	}
+}